@@ -0,0 +1,132 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bhutch29/abv/model"
+	"github.com/julienschmidt/httprouter"
+	"github.com/rs/cors"
+)
+
+// authenticate wraps a handler so that it can only be used with an API token
+// granted the given scope, sent as "Authorization: Bearer <token>".
+//
+// Routes with the menu scope are public unless apiPublicMenu is disabled.
+func authenticate(scope string, h httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		if scope == model.ScopeMenu && conf.GetBool("apiPublicMenu") {
+			h(w, r, ps)
+			return
+		}
+
+		t, ok := requestToken(w, r)
+		if !ok {
+			return
+		}
+		if !t.Allows(scope) {
+			writeError(w, http.StatusForbidden, "API token "+t.Name+" does not have the "+scope+" scope")
+			return
+		}
+		h(w, r, ps)
+	}
+}
+
+// requestToken returns the API token a request was sent with. If the token
+// is missing or invalid, an error is written and false is returned.
+func requestToken(w http.ResponseWriter, r *http.Request) (model.Token, bool) {
+	secret := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if secret == "" || secret == r.Header.Get("Authorization") {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="abv"`)
+		writeError(w, http.StatusUnauthorized, "missing API token")
+		return model.Token{}, false
+	}
+	t, err := m.Authenticate(secret)
+	if err == model.ErrInvalidToken {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="abv", error="invalid_token"`)
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return t, false
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return t, false
+	}
+	return t, true
+}
+
+// getToken returns the name and scopes of the API token the request was sent
+// with, so other services can check tokens against the API.
+func getToken(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if t, ok := requestToken(w, r); ok {
+		encodeValue(t, nil, w)
+	}
+}
+
+// corsHandler allows browsers on the configured corsOrigins to use the API.
+// Without corsOrigins, pages on any origin can only make GET requests, which
+// is enough for menus.
+func corsHandler(h http.Handler) http.Handler {
+	origins := conf.GetStringSlice("corsOrigins")
+	if len(origins) == 0 {
+		return cors.New(cors.Options{AllowedMethods: []string{http.MethodGet}}).Handler(h)
+	}
+	return cors.New(cors.Options{
+		AllowedOrigins: origins,
+		AllowedMethods: []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete},
+		AllowedHeaders: []string{"Authorization", "Content-Type"},
+	}).Handler(h)
+}
+
+// tokenCommand manages API tokens from the command line:
+//
+//	token create <name> <scope>...
+//	token list
+//	token revoke <name>
+func tokenCommand(args []string) error {
+	usage := errors.New("usage: token create <name> <scope>... | token list | token revoke <name>\nscopes: " + strings.Join(model.Scopes, ", "))
+	if len(args) == 0 {
+		return usage
+	}
+
+	switch args[0] {
+	case "create":
+		if len(args) < 3 {
+			return usage
+		}
+		secret, err := m.CreateToken(args[1], args[2:])
+		if err != nil {
+			return err
+		}
+		fmt.Println(secret)
+		fmt.Fprintln(os.Stderr, "Store this token now, it cannot be shown again")
+	case "list":
+		tokens, err := m.GetTokens()
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tSCOPES\tCREATED")
+		for _, t := range tokens {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", t.Name, t.Scopes, time.Unix(int64(t.Created), 0).Format("2006-01-02 15:04"))
+		}
+		return tw.Flush()
+	case "revoke":
+		if len(args) != 2 {
+			return usage
+		}
+		err := m.DeleteToken(args[1])
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("no token named %q", args[1])
+		}
+		return err
+	default:
+		return usage
+	}
+	return nil
+}
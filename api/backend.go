@@ -0,0 +1,158 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/bhutch29/abv/model"
+	"github.com/julienschmidt/httprouter"
+)
+
+// These routes let model.Client use the API as a model.Backend, so the
+// ABV user interface can run on a different host than the database.
+
+func getDrink(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	d, err := m.GetDrinkByBarcode(ps.ByName("barcode"))
+	if err == sql.ErrNoRows {
+		writeError(w, http.StatusNotFound, "drink not found")
+		return
+	}
+	details := drinkDetails{Drink: d}
+	if err == nil {
+		details.Count, err = m.GetCountByBarcode(d.Barcode)
+	}
+	encodeValue(details, err, w)
+}
+
+func getDrinkCount(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	count, err := m.GetCountByBarcode(ps.ByName("barcode"))
+	encodeValue(count, err, w)
+}
+
+func createDrink(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	var d model.Drink
+	if err := json.NewDecoder(r.Body).Decode(&d); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if d.Barcode == "" {
+		writeError(w, http.StatusBadRequest, "drink has no barcode")
+		return
+	}
+	id, err := m.CreateDrink(d)
+	if err == nil {
+		logos.Enqueue(d.Logo)
+	}
+	encodeCreated(id, err, w)
+}
+
+func deleteDrink(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	err := m.DeleteDrink(ps.ByName("barcode"))
+	encodeNoContent(err, w)
+}
+
+func inputDrinks(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	var de model.DrinkEntry
+	if err := decodeEntry(r, &de); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	id, err := m.InputDrinks(de)
+	encodeCreated(id, err, w)
+}
+
+func undoInputDrinks(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id, err := strconv.Atoi(ps.ByName("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	encodeNoContent(m.UndoInputDrinks(id), w)
+}
+
+func clearInput(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	encodeNoContent(m.ClearInputTable(), w)
+}
+
+func outputDrinks(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	var de model.DrinkEntry
+	if err := decodeEntry(r, &de); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	id, err := m.OutputDrinks(de)
+	encodeCreated(id, err, w)
+}
+
+func undoOutputDrinks(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id, err := strconv.Atoi(ps.ByName("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	encodeNoContent(m.UndoOutputDrinks(id), w)
+}
+
+func clearOutput(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	encodeNoContent(m.ClearOutputTable(), w)
+}
+
+func correctDrinks(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	var de model.DrinkEntry
+	if err := decodeCorrection(r, &de); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	id, err := m.CorrectDrinks(de)
+	encodeCreated(id, err, w)
+}
+
+func undoCorrectDrinks(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id, err := strconv.Atoi(ps.ByName("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	encodeNoContent(m.UndoCorrectDrinks(id), w)
+}
+
+func clearCorrections(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	encodeNoContent(m.ClearCorrectionsTable(), w)
+}
+
+// decodeEntry reads a drink entry with a barcode and a positive quantity
+func decodeEntry(r *http.Request, de *model.DrinkEntry) error {
+	if err := readEntry(r, de); err != nil {
+		return err
+	}
+	if de.Quantity < 1 {
+		return errors.New("drink entry quantity must be at least 1")
+	}
+	return nil
+}
+
+// decodeCorrection reads a drink entry with a barcode and a quantity which
+// is added to the stock of the drink. It is negative if fewer drinks were
+// counted than were in stock.
+func decodeCorrection(r *http.Request, de *model.DrinkEntry) error {
+	if err := readEntry(r, de); err != nil {
+		return err
+	}
+	if de.Quantity == 0 {
+		return errors.New("correction quantity must not be 0")
+	}
+	return nil
+}
+
+func readEntry(r *http.Request, de *model.DrinkEntry) error {
+	if err := json.NewDecoder(r.Body).Decode(de); err != nil {
+		return err
+	}
+	if de.Barcode == "" {
+		return errors.New("drink entry has no barcode")
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bhutch29/abv/model"
+	"github.com/julienschmidt/httprouter"
+)
+
+// drinkDetails is the response to a drink request. It decodes as a
+// model.Drink for model.Client.
+type drinkDetails struct {
+	model.Drink
+	Count int
+}
+
+// transactions is the response to a transactions request
+type transactions struct {
+	From   model.Date
+	To     model.Date
+	Input  []model.StockedDrink
+	Output []model.StockedDrink
+}
+
+// getDrinks returns the full catalog of drinks, including those out of stock.
+func getDrinks(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	drinks, err := m.GetCatalog()
+	encodeDrinks(drinks, err, w)
+}
+
+// getDrinkHistory returns every stocking and serving record of a drink.
+func getDrinkHistory(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	bc := ps.ByName("barcode")
+	exists, err := m.BarcodeExists(bc)
+	if err == nil && !exists {
+		writeError(w, http.StatusNotFound, "drink not found")
+		return
+	}
+	history, err := m.GetHistoryByBarcode(bc)
+	encodeValue(history, err, w)
+}
+
+// getTransactions returns the drinks stocked and served between the from and
+// to query parameters, inclusive. Dates are Unix times, RFC 3339 times or
+// days like 2006-01-02. Defaults to all time.
+func getTransactions(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	dates := model.DateRange{Start: 0, End: model.Date(time.Now().Unix())}
+	for _, p := range []struct {
+		name string
+		dest *model.Date
+	}{{"from", &dates.Start}, {"to", &dates.End}} {
+		if s := r.URL.Query().Get(p.name); s != "" {
+			d, err := parseDate(s)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "invalid "+p.name+": "+s)
+				return
+			}
+			*p.dest = d
+		}
+	}
+
+	t := transactions{From: dates.Start, To: dates.End}
+	var err error
+	if t.Input, err = m.GetInputWithinDateRange(dates); err == nil {
+		t.Output, err = m.GetOutputWithinDateRange(dates)
+	}
+	encodeValue(t, err, w)
+}
+
+// parseDate parses a Unix time, an RFC 3339 time or a day
+func parseDate(s string) (model.Date, error) {
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return model.Date(i), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return model.Date(t.Unix()), nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", s, time.Local)
+	return model.Date(t.Unix()), err
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bhutch29/abv/cache"
+	"github.com/bhutch29/abv/model"
+	"github.com/julienschmidt/httprouter"
+)
+
+// health is the response to a health check
+type health struct {
+	Alive         bool        `json:"alive"`
+	Database      string      `json:"database"`
+	SchemaVersion int         `json:"schemaVersion"`
+	Version       string      `json:"version"`
+	ImageCache    cacheHealth `json:"imageCache"`
+}
+
+// cacheHealth describes the image cache in a health check
+type cacheHealth struct {
+	Path   string `json:"path"`
+	Images int    `json:"images"`
+	Bytes  int64  `json:"bytes"`
+	Error  string `json:"error,omitempty"`
+}
+
+// healthCheck reports whether the database can be used, along with the
+// versions and the state of the image cache. The response is 503 Service
+// Unavailable if the database cannot be used.
+func healthCheck(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	h := health{Alive: true, Database: "ok", Version: version}
+	status := http.StatusOK
+
+	err := m.Ping()
+	if err == nil {
+		h.SchemaVersion, err = m.GetSchemaVersion()
+	}
+	if err != nil {
+		h.Alive = false
+		h.Database = err.Error()
+		status = http.StatusServiceUnavailable
+	}
+
+	stats, err := cache.GetStats()
+	h.ImageCache = cacheHealth{Path: stats.Path, Images: stats.Images, Bytes: stats.Bytes}
+	if err != nil {
+		h.ImageCache.Error = err.Error()
+	}
+
+	writeJSON(w, status, h)
+}
+
+// durationBuckets are the upper bounds in seconds of the request duration histogram
+var durationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// servingsWindow is the period the servings per minute are averaged over
+const servingsWindow = 15 * time.Minute
+
+// requestKey identifies the requests counted together
+type requestKey struct {
+	route  string
+	method string
+	code   int
+}
+
+// histogram counts request durations in durationBuckets
+type histogram struct {
+	buckets []int
+	sum     float64
+	count   int
+}
+
+// metrics are the request statistics collected since the API started
+var metrics = struct {
+	sync.Mutex
+	requests  map[requestKey]int
+	durations map[string]*histogram
+}{
+	requests:  make(map[requestKey]int),
+	durations: make(map[string]*histogram),
+}
+
+// statusRecorder remembers the status code written to a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// instrument wraps a handler to count its requests and their durations
+// under the given route.
+func instrument(route string, h httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		h(rec, r, ps)
+		elapsed := time.Since(start).Seconds()
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		metrics.Lock()
+		defer metrics.Unlock()
+		metrics.requests[requestKey{route, r.Method, rec.status}]++
+		hist, ok := metrics.durations[route]
+		if !ok {
+			hist = &histogram{buckets: make([]int, len(durationBuckets))}
+			metrics.durations[route] = hist
+		}
+		for i, le := range durationBuckets {
+			if elapsed <= le {
+				hist.buckets[i]++
+			}
+		}
+		hist.sum += elapsed
+		hist.count++
+	}
+}
+
+// getMetrics reports request statistics, inventory totals and the recent
+// servings per minute in the Prometheus text format.
+func getMetrics(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	quantity, err := m.GetInventoryTotalQuantity()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	variety, err := m.GetInventoryTotalVariety()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	served, err := m.GetServedSince(model.Date(time.Now().Add(-servingsWindow).Unix()))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	writeMetric(w, "abv_inventory_quantity", "gauge", "Number of drinks in stock.", quantity)
+	writeMetric(w, "abv_inventory_variety", "gauge", "Number of different drinks in stock.", variety)
+	writeMetric(w, "abv_servings_per_minute", "gauge", "Drinks served per minute over the last 15 minutes.", float64(served)/servingsWindow.Minutes())
+
+	metrics.Lock()
+	defer metrics.Unlock()
+
+	var keys []requestKey
+	for k := range metrics.requests {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.route != b.route {
+			return a.route < b.route
+		}
+		if a.method != b.method {
+			return a.method < b.method
+		}
+		return a.code < b.code
+	})
+	fmt.Fprintln(w, "# HELP abv_http_requests_total Number of API requests by route, method and status code.")
+	fmt.Fprintln(w, "# TYPE abv_http_requests_total counter")
+	for _, k := range keys {
+		fmt.Fprintf(w, "abv_http_requests_total{route=%q,method=%q,code=\"%d\"} %d\n", k.route, k.method, k.code, metrics.requests[k])
+	}
+
+	var routes []string
+	for route := range metrics.durations {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+	fmt.Fprintln(w, "# HELP abv_http_request_duration_seconds Duration of API requests by route.")
+	fmt.Fprintln(w, "# TYPE abv_http_request_duration_seconds histogram")
+	for _, route := range routes {
+		hist := metrics.durations[route]
+		for i, le := range durationBuckets {
+			fmt.Fprintf(w, "abv_http_request_duration_seconds_bucket{route=%q,le=%q} %d\n", route, strconv.FormatFloat(le, 'g', -1, 64), hist.buckets[i])
+		}
+		fmt.Fprintf(w, "abv_http_request_duration_seconds_bucket{route=%q,le=\"+Inf\"} %d\n", route, hist.count)
+		fmt.Fprintf(w, "abv_http_request_duration_seconds_sum{route=%q} %g\n", route, hist.sum)
+		fmt.Fprintf(w, "abv_http_request_duration_seconds_count{route=%q} %d\n", route, hist.count)
+	}
+}
+
+// writeMetric writes a single metric without labels
+func writeMetric(w io.Writer, name, kind, help string, value interface{}) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %v\n", name, help, name, kind, name, value)
+}
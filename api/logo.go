@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/bhutch29/abv/cache"
+	"github.com/julienschmidt/httprouter"
+)
+
+// putDrinkLogo sets the custom logo of a drink to the image in the request
+// body, which is shown instead of its Untappd logo.
+func putDrinkLogo(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	bc := ps.ByName("barcode")
+	if !drinkExists(w, bc) {
+		return
+	}
+	saveLogo(w, r, cache.DrinkLogo(bc))
+}
+
+// deleteDrinkLogo removes the custom logo of a drink.
+func deleteDrinkLogo(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	bc := ps.ByName("barcode")
+	if !drinkExists(w, bc) {
+		return
+	}
+	removeLogo(w, cache.DrinkLogo(bc))
+}
+
+// putBrandLogo sets the custom logo of every drink of a brand to the image in
+// the request body. Custom logos of single drinks take precedence.
+func putBrandLogo(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	brand, ok := storedBrand(w, ps.ByName("brand"))
+	if !ok {
+		return
+	}
+	saveLogo(w, r, cache.BrandLogo(brand))
+}
+
+// deleteBrandLogo removes the custom logo of a brand.
+func deleteBrandLogo(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	brand, ok := storedBrand(w, ps.ByName("brand"))
+	if !ok {
+		return
+	}
+	removeLogo(w, cache.BrandLogo(brand))
+}
+
+// storedBrand returns the brand as stored of the drinks with the given
+// brand, which may also be the brand they are displayed with after
+// nicknames. It writes a not found response if no drink has the brand, and
+// a conflict response if drinks of several stored brands are displayed with
+// it.
+func storedBrand(w http.ResponseWriter, brand string) (string, bool) {
+	drinks, err := m.GetAllStoredDrinks()
+	if err != nil {
+		encodeError(err, w)
+		return "", false
+	}
+	shownAs := make(map[string]bool)
+	for _, d := range drinks {
+		if d.StoredBrand == brand {
+			return brand, true
+		}
+		if d.Brand == brand {
+			shownAs[d.StoredBrand] = true
+		}
+	}
+	switch len(shownAs) {
+	case 0:
+		writeError(w, http.StatusNotFound, "brand not found")
+	case 1:
+		for stored := range shownAs {
+			return stored, true
+		}
+	default:
+		writeError(w, http.StatusConflict, fmt.Sprintf("%d brands are displayed as %q, use the brand as stored", len(shownAs), brand))
+	}
+	return "", false
+}
+
+// drinkExists writes a not found response if no drink has the barcode
+func drinkExists(w http.ResponseWriter, bc string) bool {
+	exists, err := m.BarcodeExists(bc)
+	if err != nil {
+		encodeError(err, w)
+		return false
+	}
+	if !exists {
+		writeError(w, http.StatusNotFound, "drink not found")
+	}
+	return exists
+}
+
+func saveLogo(w http.ResponseWriter, r *http.Request, name string) {
+	e, err := cache.Save(name, r.Body)
+	switch {
+	case errors.Is(err, cache.ErrNotImage):
+		writeError(w, http.StatusUnsupportedMediaType, err.Error())
+	case errors.Is(err, cache.ErrTooLarge):
+		writeError(w, http.StatusRequestEntityTooLarge, err.Error())
+	case err != nil:
+		encodeError(err, w)
+	default:
+		writeJSON(w, http.StatusOK, struct{ Image string }{e.File})
+	}
+}
+
+func removeLogo(w http.ResponseWriter, name string) {
+	err := cache.Remove(name)
+	if os.IsNotExist(err) {
+		writeError(w, http.StatusNotFound, "no custom logo")
+		return
+	}
+	encodeNoContent(err, w)
+}
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
@@ -10,18 +9,32 @@ import (
 	"os"
 
 	"net/url"
+	"time"
 
 	"github.com/bhutch29/abv/cache"
+	"github.com/bhutch29/abv/config"
 	"github.com/bhutch29/abv/model"
 	"github.com/julienschmidt/httprouter"
-	"github.com/rs/cors"
+	"github.com/spf13/viper"
 )
 
 var (
 	m       model.Model
+	conf    *viper.Viper
+	client  = &http.Client{Timeout: 5 * time.Second}
+	logos   = cache.NewPrefetcher(256, 3, 30*time.Second)
 	version = "undefined"
 )
 
+// Logos of drinks created elsewhere, such as by an ABV user interface using
+// the database directly, are looked for every logoScanInterval. Every drink
+// is looked at again every logoRescanInterval, in case its logo could not be
+// queued.
+const (
+	logoScanInterval   = 15 * time.Second
+	logoRescanInterval = time.Hour
+)
+
 func main() {
 	handleFlags()
 
@@ -31,20 +44,117 @@ func main() {
 	}
 	m = mod
 
-	router := httprouter.New()
+	if conf, err = config.New(); err != nil {
+		log.Fatal(err)
+	}
+
+	if flag.Arg(0) == "token" {
+		if err := tokenCommand(flag.Args()[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	logos.OnError = func(url string, err error, willRetry bool) {
+		log.Println("Failed to cache image ", url, ": ", err, " (retrying: ", willRetry, ")")
+	}
+	go logos.Run()
+	go prefetchLogos()
+
+	log.Fatal(http.ListenAndServe(":8081", corsHandler(newRouter())))
+}
 
-	router.GET("/health", healthCheck)
-	router.GET("/inventory", getInventory)
-	router.GET("/inventory/quantity", getInventoryQuantity)
-	router.GET("/inventory/variety", getInventoryVariety)
-	router.GET("/inventory/sorted/:sortFields", getInventorySorted)
+// prefetchLogos queues the logos of all drinks to be cached, then the logos
+// of drinks created since the last scan every logoScanInterval.
+func prefetchLogos() {
+	var since model.Date
+	rescanned := time.Now()
+	for {
+		scanned := model.Date(time.Now().Unix())
+		drinks, err := m.GetDrinksCreatedSince(since)
+		if err != nil {
+			log.Println("Failed to get drinks to cache logos for: ", err)
+		} else {
+			since = scanned
+		}
+		for _, d := range drinks {
+			logos.Enqueue(d.Logo)
+		}
+
+		time.Sleep(logoScanInterval)
+		if time.Since(rescanned) >= logoRescanInterval {
+			since = 0
+			rescanned = time.Now()
+		}
+	}
+}
+
+// route is an API endpoint. Routes without a scope are public.
+type route struct {
+	method string
+	path   string
+	scope  string
+	handle httprouter.Handle
+}
 
-	corsEnabledHandler := cors.Default().Handler(router)
-	log.Fatal(http.ListenAndServe(":8081", corsEnabledHandler))
+var routes = []route{
+	{"GET", "/health", "", healthCheck},
+	{"GET", "/metrics", "", getMetrics},
+	{"GET", "/inventory", model.ScopeMenu, getInventory},
+	{"GET", "/inventory/quantity", model.ScopeMenu, getInventoryQuantity},
+	{"GET", "/inventory/variety", model.ScopeMenu, getInventoryVariety},
+	{"GET", "/inventory/sorted/:sortFields", model.ScopeMenu, getInventorySorted},
+	{"GET", "/station", model.ScopeMenu, getStationStatus},
+	{"GET", "/token", "", getToken},
+
+	{"GET", "/drinks", model.ScopeMenu, getDrinks},
+	{"GET", "/drinks/:barcode", model.ScopeMenu, getDrink},
+	{"GET", "/drinks/:barcode/count", model.ScopeMenu, getDrinkCount},
+	{"GET", "/drinks/:barcode/history", model.ScopeStock, getDrinkHistory},
+	{"GET", "/transactions", model.ScopeStock, getTransactions},
+	{"POST", "/drinks", model.ScopeStock, createDrink},
+	{"DELETE", "/drinks/:barcode", model.ScopeStock, deleteDrink},
+	{"PUT", "/drinks/:barcode/logo", model.ScopeStock, putDrinkLogo},
+	{"DELETE", "/drinks/:barcode/logo", model.ScopeStock, deleteDrinkLogo},
+	{"PUT", "/brands/:brand/logo", model.ScopeStock, putBrandLogo},
+	{"DELETE", "/brands/:brand/logo", model.ScopeStock, deleteBrandLogo},
+	{"POST", "/input", model.ScopeStock, inputDrinks},
+	{"DELETE", "/input", model.ScopeAdmin, clearInput},
+	{"DELETE", "/input/:id", model.ScopeStock, undoInputDrinks},
+	{"POST", "/output", model.ScopeServe, outputDrinks},
+	{"DELETE", "/output", model.ScopeAdmin, clearOutput},
+	{"DELETE", "/output/:id", model.ScopeServe, undoOutputDrinks},
+	{"POST", "/corrections", model.ScopeStock, correctDrinks},
+	{"DELETE", "/corrections", model.ScopeAdmin, clearCorrections},
+	{"DELETE", "/corrections/:id", model.ScopeStock, undoCorrectDrinks},
+}
+
+// newRouter registers every route with authentication and request metrics
+func newRouter() *httprouter.Router {
+	router := httprouter.New()
+	for _, r := range routes {
+		h := r.handle
+		if r.scope != "" {
+			h = authenticate(r.scope, h)
+		}
+		router.Handle(r.method, r.path, instrument(r.path, h))
+	}
+	router.NotFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, "no route for "+r.URL.Path)
+	})
+	router.MethodNotAllowed = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusMethodNotAllowed, r.Method+" is not allowed for "+r.URL.Path)
+	})
+	return router
 }
 
 func handleFlags() {
 	ver := flag.Bool("version", false, "Prints the version")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags]\n       %s token create <name> <scope>... | token list | token revoke <name>\n", os.Args[0], os.Args[0])
+		flag.PrintDefaults()
+	}
 	flag.Parse()
 
 	if *ver {
@@ -53,15 +163,20 @@ func handleFlags() {
 	}
 }
 
-func healthCheck(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-	w.WriteHeader(http.StatusOK)
-	setHeader(w)
-	// TODO: Expand Health Check
-	io.WriteString(w, `{"alive": true}`)
-}
-
+// getInventory returns the stocked drinks matching the query parameters
+// sort, order, style, brand, country, min_abv, max_abv, q, min_quantity,
+// limit and offset.
+// Drinks are sorted by shorttype, brand and name by default.
 func getInventory(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-	drinks, err := m.GetInventory()
+	q, err := model.ParseInventoryQuery(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(q.Sort) == 0 {
+		q.Sort = []string{"shorttype", "brand", "name"}
+	}
+	drinks, err := m.QueryInventory(q)
 	encodeDrinks(drinks, err, w)
 }
 
@@ -75,39 +190,36 @@ func getInventoryVariety(w http.ResponseWriter, r *http.Request, ps httprouter.P
 	encodeValue(q, err, w)
 }
 
+// getInventorySorted is the old form of /inventory?sort=, with the query
+// string sortBy=field&sortBy=field in the path.
 func getInventorySorted(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	res, err := url.ParseQuery(ps.ByName("sortFields"))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
 	}
-	drinks, err := m.GetInventorySorted(res["sortBy"])
-	encodeDrinks(drinks, err, w)
-}
-
-func encodeValue(val interface{}, err error, w http.ResponseWriter) {
-	setHeader(w)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	q := model.InventoryQuery{Sort: res["sortBy"]}
+	if err := q.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
 	}
-	json.NewEncoder(w).Encode(val)
+	drinks, err := m.QueryInventory(q)
+	encodeDrinks(drinks, err, w)
 }
 
-func encodeDrinks(drinks []model.StockedDrink, err error, w http.ResponseWriter) {
-	setHeader(w)
+// getStationStatus relays the status of the headless abv at stationUrl.
+func getStationStatus(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	resp, err := client.Get("http://" + conf.GetString("stationUrl") + "/status")
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
 	}
-
-	for _, drink := range drinks {
-		err = cache.Image(drink.Logo)
-		if err != nil {
-			log.Println("Failed HTTP request while caching image for drink: ", drink.Brand, " ", drink.Name)
-		}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		writeError(w, http.StatusBadGateway, "station responded with "+resp.Status)
+		return
 	}
-
-	json.NewEncoder(w).Encode(drinks)
-}
-
-func setHeader(w http.ResponseWriter) {
-	w.Header().Set("Content-Type", "application/json")
+	setHeader(w)
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
 }
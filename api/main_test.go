@@ -0,0 +1,296 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bhutch29/abv/model"
+	"github.com/spf13/viper"
+)
+
+var tokens = make(map[string]string)
+
+func TestMain(tm *testing.M) {
+	nicknames := viper.New()
+	nicknames.Set("breweryNicknames", map[string]string{"bayerische staatsbrauerei weihenstephan": "Weihenstephaner"})
+	nicknames.Set("styleNicknames", map[string]string{"american wild ale": "Sour"})
+	mod, err := model.Open(":memory:", nicknames)
+	if err != nil {
+		panic(err)
+	}
+	m = mod
+
+	station := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"Mode": "serving", "Quantity": 1}`))
+	}))
+	defer station.Close()
+
+	conf = viper.New()
+	conf.Set("apiPublicMenu", true)
+	conf.Set("stationUrl", strings.TrimPrefix(station.URL, "http://"))
+
+	for _, scope := range model.Scopes {
+		if tokens[scope], err = m.CreateToken(scope, []string{scope}); err != nil {
+			panic(err)
+		}
+	}
+	m.CreateDrink(model.Drink{Barcode: "100", Brand: "Brewery", Name: "Test"})
+	m.InputDrinks(model.DrinkEntry{Barcode: "100", Quantity: 5})
+	for _, d := range inventoryDrinks {
+		m.CreateDrink(d.Drink)
+		m.InputDrinks(model.DrinkEntry{Barcode: d.Barcode, Quantity: d.Quantity})
+	}
+
+	code := tm.Run()
+	m.Close()
+	os.Exit(code)
+}
+
+// inventoryDrinks are stocked for TestInventoryQueries, along with drink 100
+var inventoryDrinks = []model.StockedDrink{
+	{Drink: model.Drink{Barcode: "301", Brand: "Allagash Brewing Company", Name: "Coolship Red", Shorttype: "American Wild Ale", Abv: 5.7}, Quantity: 2},
+	{Drink: model.Drink{Barcode: "302", Brand: "Crooked Stave", Name: "Origins", Shorttype: "Sour", Abv: 6.5}, Quantity: 4},
+	{Drink: model.Drink{Barcode: "303", Brand: "Bayerische Staatsbrauerei Weihenstephan", Name: "Hefeweissbier", Shorttype: "Hefeweizen", Abv: 5.4}, Quantity: 3},
+	{Drink: model.Drink{Barcode: "304", Brand: "Firestone Walker Brewing Company", Name: "Union Jack", Shorttype: "IPA", Abv: 7.5}, Quantity: 1},
+}
+
+// TestInventoryQueries checks which drinks are returned in which order for
+// each inventory query parameter. It runs before TestRoutes clears the
+// inventory.
+func TestInventoryQueries(t *testing.T) {
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"/inventory", []string{"100", "303", "304", "301", "302"}},
+		{"/inventory?sort=brand", []string{"100", "301", "302", "304", "303"}},
+		{"/inventory?sort=brand&order=desc", []string{"303", "304", "302", "301", "100"}},
+		{"/inventory?sort=quantity&min_abv=5", []string{"304", "301", "303", "302"}},
+		{"/inventory?sort=abv&order=desc&limit=2", []string{"304", "302"}},
+		{"/inventory?sort=abv&limit=2&offset=1", []string{"303", "301"}},
+		{"/inventory?sort=abv&offset=10", nil},
+		{"/inventory?style=sour&sort=name", []string{"301", "302"}},
+		{"/inventory?brand=weihenstephaner", []string{"303"}},
+		{"/inventory?q=firestone", []string{"304"}},
+		{"/inventory?min_quantity=4&sort=quantity", []string{"302", "100"}},
+		{"/inventory?min_abv=5&max_abv=6&sort=abv", []string{"303", "301"}},
+		{"/inventory/sorted/sortBy=name", []string{"301", "303", "302", "100", "304"}},
+	}
+	router := newRouter()
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest("GET", tt.path, nil))
+		var drinks []model.StockedDrink
+		if err := json.NewDecoder(rec.Body).Decode(&drinks); err != nil {
+			t.Errorf("%s: %v", tt.path, err)
+			continue
+		}
+		var got []string
+		for _, d := range drinks {
+			got = append(got, d.Barcode)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got drinks %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+// routeTest is a request to a route and its expected response status
+type routeTest struct {
+	route  string // the path of the route in routes
+	method string
+	path   string
+	token  string // scope of the token to send, "bad" for an unknown token
+	body   string
+	status int
+}
+
+// routeTests are run in order, as some depend on the changes of earlier tests
+var routeTests = []routeTest{
+	{"/health", "GET", "/health", "", "", http.StatusOK},
+	{"/metrics", "GET", "/metrics", "", "", http.StatusOK},
+	{"/inventory", "GET", "/inventory", "", "", http.StatusOK},
+	{"/inventory", "GET", "/inventory?sort=brand,name&order=desc&limit=5", "", "", http.StatusOK},
+	{"/inventory", "GET", "/inventory?sort=bogus", "", "", http.StatusBadRequest},
+	{"/inventory", "GET", "/inventory?min_abv=strong", "", "", http.StatusBadRequest},
+	{"/inventory", "GET", "/inventory?style=sour&min_quantity=3", "", "", http.StatusOK},
+	{"/inventory", "GET", "/inventory?min_quantity=-1", "", "", http.StatusBadRequest},
+	{"/inventory/quantity", "GET", "/inventory/quantity", "", "", http.StatusOK},
+	{"/inventory/variety", "GET", "/inventory/variety", "", "", http.StatusOK},
+	{"/inventory/sorted/:sortFields", "GET", "/inventory/sorted/sortBy=name", "", "", http.StatusOK},
+	{"/inventory/sorted/:sortFields", "GET", "/inventory/sorted/sortBy=bogus", "", "", http.StatusBadRequest},
+	{"/station", "GET", "/station", "", "", http.StatusOK},
+	{"/token", "GET", "/token", "", "", http.StatusUnauthorized},
+	{"/token", "GET", "/token", "bad", "", http.StatusUnauthorized},
+	{"/token", "GET", "/token", "serve", "", http.StatusOK},
+
+	{"/drinks", "GET", "/drinks", "", "", http.StatusOK},
+	{"/drinks/:barcode", "GET", "/drinks/100", "", "", http.StatusOK},
+	{"/drinks/:barcode", "GET", "/drinks/999", "", "", http.StatusNotFound},
+	{"/drinks/:barcode/count", "GET", "/drinks/100/count", "", "", http.StatusOK},
+	{"/drinks/:barcode/history", "GET", "/drinks/100/history", "", "", http.StatusUnauthorized},
+	{"/drinks/:barcode/history", "GET", "/drinks/100/history", "menu", "", http.StatusForbidden},
+	{"/drinks/:barcode/history", "GET", "/drinks/100/history", "stock", "", http.StatusOK},
+	{"/drinks/:barcode/history", "GET", "/drinks/999/history", "stock", "", http.StatusNotFound},
+	{"/transactions", "GET", "/transactions?from=2020-01-01", "stock", "", http.StatusOK},
+	{"/transactions", "GET", "/transactions?from=yesterday", "stock", "", http.StatusBadRequest},
+
+	{"/drinks", "POST", "/drinks", "bad", `{"Barcode": "200"}`, http.StatusUnauthorized},
+	{"/drinks", "POST", "/drinks", "serve", `{"Barcode": "200"}`, http.StatusForbidden},
+	{"/drinks", "POST", "/drinks", "stock", `{"Barcode": "200"}`, http.StatusCreated},
+	{"/drinks", "POST", "/drinks", "stock", `{"Barcode": "200"}`, http.StatusConflict},
+	{"/drinks", "POST", "/drinks", "stock", `{"Name": "No Barcode"}`, http.StatusBadRequest},
+	{"/drinks", "POST", "/drinks", "stock", `{"Barcode": `, http.StatusBadRequest},
+	{"/drinks/:barcode", "DELETE", "/drinks/200", "stock", "", http.StatusNoContent},
+	{"/drinks/:barcode/logo", "PUT", "/drinks/100/logo", "menu", "", http.StatusForbidden},
+	{"/drinks/:barcode/logo", "PUT", "/drinks/999/logo", "stock", "", http.StatusNotFound},
+	{"/drinks/:barcode/logo", "PUT", "/drinks/100/logo", "stock", "<html></html>", http.StatusUnsupportedMediaType},
+	{"/drinks/:barcode/logo", "DELETE", "/drinks/100/logo", "stock", "", http.StatusNotFound},
+	{"/brands/:brand/logo", "PUT", "/brands/Brewery/logo", "stock", "<html></html>", http.StatusUnsupportedMediaType},
+	{"/brands/:brand/logo", "PUT", "/brands/Allagash/logo", "stock", "<html></html>", http.StatusUnsupportedMediaType},
+	{"/brands/:brand/logo", "PUT", "/brands/Nobody/logo", "stock", "<html></html>", http.StatusNotFound},
+	{"/brands/:brand/logo", "DELETE", "/brands/Brewery/logo", "stock", "", http.StatusNotFound},
+	{"/brands/:brand/logo", "DELETE", "/brands/Nobody/logo", "stock", "", http.StatusNotFound},
+
+	{"/input", "POST", "/input", "stock", `{"Barcode": "100", "Quantity": 2}`, http.StatusCreated},
+	{"/input", "POST", "/input", "stock", `{"Barcode": "100", "Quantity": 0}`, http.StatusBadRequest},
+	{"/input", "POST", "/input", "stock", `{"Barcode": "100", "Quantity": -1}`, http.StatusBadRequest},
+	{"/input", "POST", "/input", "serve", `{"Barcode": "100", "Quantity": 2}`, http.StatusForbidden},
+	{"/input/:id", "DELETE", "/input/2", "stock", "", http.StatusNoContent},
+	{"/input/:id", "DELETE", "/input/two", "stock", "", http.StatusBadRequest},
+	{"/output", "POST", "/output", "serve", `{"Barcode": "100", "Quantity": 1}`, http.StatusCreated},
+	{"/output", "POST", "/output", "serve", `{"Quantity": 1}`, http.StatusBadRequest},
+	{"/output/:id", "DELETE", "/output/1", "serve", "", http.StatusNoContent},
+	{"/output/:id", "DELETE", "/output/one", "serve", "", http.StatusBadRequest},
+	{"/output", "POST", "/output", "serve", `{"Barcode": "100", "Quantity": -1}`, http.StatusBadRequest},
+	{"/corrections", "POST", "/corrections", "serve", `{"Barcode": "100", "Quantity": 1}`, http.StatusForbidden},
+	{"/corrections", "POST", "/corrections", "stock", `{"Barcode": "100", "Quantity": -1}`, http.StatusCreated},
+	{"/corrections", "POST", "/corrections", "stock", `{"Barcode": "100", "Quantity": 0}`, http.StatusBadRequest},
+	{"/corrections/:id", "DELETE", "/corrections/1", "serve", "", http.StatusForbidden},
+	{"/corrections/:id", "DELETE", "/corrections/1", "stock", "", http.StatusNoContent},
+	{"/corrections/:id", "DELETE", "/corrections/one", "stock", "", http.StatusBadRequest},
+	{"/input", "DELETE", "/input", "stock", "", http.StatusForbidden},
+	{"/input", "DELETE", "/input", "admin", "", http.StatusNoContent},
+	{"/output", "DELETE", "/output", "admin", "", http.StatusNoContent},
+	{"/corrections", "DELETE", "/corrections", "stock", "", http.StatusForbidden},
+	{"/corrections", "DELETE", "/corrections", "admin", "", http.StatusNoContent},
+
+	{"", "GET", "/nothing", "", "", http.StatusNotFound},
+	{"", "PUT", "/drinks", "stock", "", http.StatusMethodNotAllowed},
+}
+
+func TestRoutes(t *testing.T) {
+	router := newRouter()
+	for _, tt := range routeTests {
+		req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		switch tt.token {
+		case "":
+		case "bad":
+			req.Header.Set("Authorization", "Bearer bad")
+		default:
+			req.Header.Set("Authorization", "Bearer "+tokens[tt.token])
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		name := tt.method + " " + tt.path
+		if rec.Code != tt.status {
+			t.Errorf("%s: wanted status %d got %d: %s", name, tt.status, rec.Code, rec.Body)
+			continue
+		}
+		checkBody(t, name, rec)
+	}
+}
+
+// checkBody checks that a response is a single JSON value, and an error
+// envelope for error statuses
+func checkBody(t *testing.T, name string, rec *httptest.ResponseRecorder) {
+	switch {
+	case rec.Code == http.StatusNoContent:
+		if rec.Body.Len() != 0 {
+			t.Errorf("%s: unexpected body %s", name, rec.Body)
+		}
+		return
+	case strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain"):
+		return
+	case rec.Header().Get("Content-Type") != "application/json":
+		t.Errorf("%s: wanted JSON got %q", name, rec.Header().Get("Content-Type"))
+		return
+	}
+
+	dec := json.NewDecoder(rec.Body)
+	if rec.Code < 400 {
+		var val interface{}
+		if err := dec.Decode(&val); err != nil {
+			t.Errorf("%s: invalid JSON: %v", name, err)
+		}
+	} else {
+		var e errorResponse
+		if err := dec.Decode(&e); err != nil {
+			t.Errorf("%s: invalid error JSON: %v", name, err)
+		}
+		if e.Error.Code != errorCodes[rec.Code] || e.Error.Message == "" {
+			t.Errorf("%s: unexpected error %+v", name, e.Error)
+		}
+	}
+	if dec.More() {
+		t.Errorf("%s: response has more than one JSON value", name)
+	}
+}
+
+func TestEveryRouteIsTested(t *testing.T) {
+	tested := make(map[string]bool)
+	for _, tt := range routeTests {
+		tested[tt.method+" "+tt.route] = true
+	}
+	for _, r := range routes {
+		if !tested[r.method+" "+r.path] {
+			t.Errorf("no test for %s %s", r.method, r.path)
+		}
+	}
+}
+
+func TestPrivateMenu(t *testing.T) {
+	conf.Set("apiPublicMenu", false)
+	defer conf.Set("apiPublicMenu", true)
+
+	router := newRouter()
+	for _, token := range []string{"", "menu", "admin"} {
+		req := httptest.NewRequest("GET", "/inventory", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+tokens[token])
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		want := http.StatusOK
+		if token == "" {
+			want = http.StatusUnauthorized
+		}
+		if rec.Code != want {
+			t.Errorf("token %q: wanted status %d got %d", token, want, rec.Code)
+		}
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	conf.Set("corsOrigins", []string{"http://bar.example.com"})
+	defer conf.Set("corsOrigins", nil)
+
+	h := corsHandler(newRouter())
+	for _, method := range []string{"GET", "POST", "PUT", "DELETE"} {
+		req := httptest.NewRequest("OPTIONS", "/drinks/100/logo", nil)
+		req.Header.Set("Origin", "http://bar.example.com")
+		req.Header.Set("Access-Control-Request-Method", method)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Header().Get("Access-Control-Allow-Origin") != "http://bar.example.com" {
+			t.Errorf("%s is not allowed from corsOrigins", method)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/bhutch29/abv/cache"
+	"github.com/bhutch29/abv/model"
+	"github.com/mattn/go-sqlite3"
+)
+
+// errorResponse is the body of every error response, e.g.
+// {"error": {"code": "not_found", "message": "drink not found"}}
+type errorResponse struct {
+	Error errorBody `json:"error"`
+}
+
+type errorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// errorCodes are the machine readable codes of the error statuses
+var errorCodes = map[int]string{
+	http.StatusBadRequest:            "bad_request",
+	http.StatusUnauthorized:          "unauthorized",
+	http.StatusForbidden:             "forbidden",
+	http.StatusNotFound:              "not_found",
+	http.StatusMethodNotAllowed:      "method_not_allowed",
+	http.StatusConflict:              "conflict",
+	http.StatusRequestEntityTooLarge: "too_large",
+	http.StatusUnsupportedMediaType:  "unsupported_media_type",
+	http.StatusInternalServerError:   "internal_error",
+	http.StatusBadGateway:            "bad_gateway",
+	http.StatusServiceUnavailable:    "unavailable",
+}
+
+// writeJSON writes a JSON response with the given status
+func writeJSON(w http.ResponseWriter, status int, val interface{}) {
+	setHeader(w)
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(val); err != nil {
+		log.Println("Failed to write response: ", err)
+	}
+}
+
+// writeError writes an error response with the given status
+func writeError(w http.ResponseWriter, status int, message string) {
+	code, ok := errorCodes[status]
+	if !ok {
+		code = "error"
+	}
+	writeJSON(w, status, errorResponse{errorBody{code, message}})
+}
+
+// encodeError writes an error response with the status matching the error
+func encodeError(err error, w http.ResponseWriter) {
+	switch e := err.(type) {
+	case *model.QueryError:
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	case sqlite3.Error:
+		if e.Code == sqlite3.ErrConstraint {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+	}
+	if err == sql.ErrNoRows {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	writeError(w, http.StatusInternalServerError, err.Error())
+}
+
+func encodeValue(val interface{}, err error, w http.ResponseWriter) {
+	if err != nil {
+		encodeError(err, w)
+		return
+	}
+	writeJSON(w, http.StatusOK, val)
+}
+
+// menuDrink is a stocked drink with the file name of its cached logo, which
+// the frontend serves from /images/. Custom logos take precedence over the
+// logo url. Image is empty until a logo is cached.
+// It decodes as a model.StockedDrink for model.Client.
+type menuDrink struct {
+	model.StockedDrink
+	Image string
+}
+
+func encodeDrinks(drinks []model.StockedDrink, err error, w http.ResponseWriter) {
+	if err != nil {
+		encodeError(err, w)
+		return
+	}
+
+	res := make([]menuDrink, len(drinks))
+	for i, d := range drinks {
+		res[i].StockedDrink = d
+		res[i].Image = cache.LogoFile(d.Barcode, d.StoredBrand, d.Logo)
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+func encodeCreated(id int, err error, w http.ResponseWriter) {
+	if err != nil {
+		encodeError(err, w)
+		return
+	}
+	writeJSON(w, http.StatusCreated, struct{ ID int }{id})
+}
+
+func encodeNoContent(err error, w http.ResponseWriter) {
+	if err != nil {
+		encodeError(err, w)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func setHeader(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+}
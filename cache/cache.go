@@ -1,54 +1,344 @@
 // Package cache provides methods for caching brand logo images.
+//
+// Images are stored under the hash of their URL, so that images with the same
+// file name from different hosts do not overwrite each other. Each image has a
+// metadata file next to it recording where and when it was fetched.
 package cache
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
 	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
 	"path"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/bhutch29/abv/config"
 	"github.com/spf13/viper"
 )
 
-var conf *viper.Viper
+var (
+	conf   *viper.Viper
+	client = &http.Client{Timeout: 30 * time.Second}
+)
+
+// maxImageBytes is the largest image which will be cached
+const maxImageBytes = 10 << 20
+
+// imageTypes are the accepted image MIME types and their file extensions
+var imageTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// Errors returned for images which cannot be cached
+var (
+	ErrNotImage = errors.New("not a JPEG, PNG, GIF or WebP image")
+	ErrTooLarge = fmt.Errorf("image is larger than %d bytes", maxImageBytes)
+)
 
 func init() {
 	var err error
 	conf, err = config.New()
 	if err != nil {
-		log.Fatal("Could not get configuration: ", err)
+		log.Println("Could not get configuration, caching images in the working directory: ", err)
+		conf = viper.New()
+	}
+}
+
+// Entry is the metadata of a cached image. Custom images are never
+// refreshed or evicted.
+type Entry struct {
+	URL         string
+	File        string
+	ContentType string
+	ETag        string
+	Fetched     time.Time
+	Size        int64
+	Custom      bool
+}
+
+// Key returns the name an image from the provided url is cached under
+func Key(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return hex.EncodeToString(sum[:])
+}
+
+// Dir returns the directory images are cached in
+func Dir() string {
+	return path.Join(conf.GetString("configPath"), "images")
+}
+
+// Lookup returns the metadata of the image from the provided url, if it is
+// cached
+func Lookup(url string) (Entry, bool) {
+	e, err := readEntry(Key(url))
+	if err != nil || !exists(path.Join(Dir(), e.File)) {
+		return Entry{}, false
 	}
+	return e, true
+}
+
+// Cached returns whether the image from the provided url is cached and was
+// fetched within imageRefresh
+func Cached(url string) bool {
+	e, ok := Lookup(url)
+	return ok && time.Since(e.Fetched) < conf.GetDuration("imageRefresh")
 }
 
-// Image queries and saves an image from the provided url if it isn't cached already
+// Image queries and saves an image from the provided url if it isn't cached
+// already. An image which was fetched longer than imageRefresh ago is fetched
+// again if it has changed. Afterwards, the least recently fetched images are
+// evicted until the cache is no larger than imageCacheSize.
 func Image(url string) error {
-	imagePath := path.Join(conf.GetString("configPath"), "images")
-	file := path.Join(imagePath, path.Base(url))
-	if exists(file) {
+	if url == "" {
+		return errors.New("no image url")
+	}
+	old, cached := Lookup(url)
+	if cached && time.Since(old.Fetched) < conf.GetDuration("imageRefresh") {
 		return nil
 	}
-	response, err := http.Get(url)
+
+	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return err
 	}
+	if cached && old.ETag != "" {
+		req.Header.Set("If-None-Match", old.ETag)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if cached && resp.StatusCode == http.StatusNotModified {
+		old.Fetched = time.Now()
+		return writeEntry(old)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching %s: %s", url, resp.Status)
+	}
+
+	e := Entry{URL: url, ETag: resp.Header.Get("ETag")}
+	if err := store(&e, resp.Body); err != nil {
+		return err
+	}
+	if cached && old.File != e.File {
+		os.Remove(path.Join(Dir(), old.File))
+		removeThumbnails(old.File)
+	}
+	// Images which the standard library cannot decode, such as WebP, are
+	// served without thumbnails
+	if err := thumbnails(e.File); err != nil && err != image.ErrFormat {
+		return err
+	}
+	return Evict(conf.GetInt64("imageCacheSize"))
+}
 
-	_ = os.MkdirAll(imagePath, os.ModePerm)
-	f, err := os.Create(file)
+// store validates and saves an image read from r, and its metadata.
+// The image is written to a temporary file first, so that a failed download
+// never replaces a cached image.
+func store(e *Entry, r io.Reader) error {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	head = head[:n]
+	e.ContentType = http.DetectContentType(head)
+	ext, ok := imageTypes[e.ContentType]
+	if !ok {
+		return fmt.Errorf("%s: %w, found %s", e.URL, ErrNotImage, e.ContentType)
+	}
+	e.File = Key(e.URL) + ext
+
+	dir := Dir()
+	if err = os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(dir, ".download-")
 	if err != nil {
-		log.Fatal("Could not create file: ", err)
+		return err
 	}
+	defer os.Remove(f.Name())
+	defer f.Close()
 
-	if _, err = io.Copy(f, response.Body); err != nil {
+	body := io.MultiReader(bytes.NewReader(head), io.LimitReader(r, maxImageBytes-int64(n)+1))
+	if e.Size, err = io.Copy(f, body); err != nil {
+		return err
+	}
+	if e.Size > maxImageBytes {
+		return fmt.Errorf("%s: %w", e.URL, ErrTooLarge)
+	}
+	if err = f.Close(); err != nil {
 		return err
 	}
 
-	response.Body.Close()
-	f.Close()
+	// The metadata is written first, so that the image is never mistaken
+	// for a file which belongs to no image
+	e.Fetched = time.Now()
+	if err = writeEntry(*e); err != nil {
+		return err
+	}
+	if err = os.Rename(f.Name(), path.Join(dir, e.File)); err != nil {
+		os.Remove(metaFile(Key(e.URL)))
+		return err
+	}
 	return nil
 }
 
+// metaFile returns the path of the metadata of the image with the given key
+func metaFile(key string) string {
+	return path.Join(Dir(), key+".json")
+}
+
+func readEntry(key string) (Entry, error) {
+	var e Entry
+	b, err := ioutil.ReadFile(metaFile(key))
+	if err != nil {
+		return e, err
+	}
+	err = json.Unmarshal(b, &e)
+	return e, err
+}
+
+func writeEntry(e Entry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(Dir(), ".meta-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if _, err = f.Write(b); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), metaFile(Key(e.URL)))
+}
+
+// entries returns the metadata of every cached image, least recently fetched
+// first, and the names of files in the cache which belong to no image, such
+// as images cached by earlier versions of ABV
+func entries() ([]Entry, []string, error) {
+	files, err := ioutil.ReadDir(Dir())
+	if os.IsNotExist(err) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var es []Entry
+	used := make(map[string]bool)
+	for _, f := range files {
+		name := f.Name()
+		if !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		e, err := readEntry(strings.TrimSuffix(name, ".json"))
+		if err != nil {
+			continue
+		}
+		used[name] = true
+		if exists(path.Join(Dir(), e.File)) {
+			es = append(es, e)
+			used[e.File] = true
+		}
+	}
+
+	var orphans []string
+	for _, f := range files {
+		name := f.Name()
+		if f.Mode().IsRegular() && !used[name] && !strings.HasPrefix(name, ".") {
+			orphans = append(orphans, name)
+		}
+	}
+
+	sort.Slice(es, func(i, j int) bool { return es[i].Fetched.Before(es[j].Fetched) })
+	return es, orphans, nil
+}
+
+// Evict removes files which belong to no image, then removes the least
+// recently fetched images until the images take up no more than max bytes.
+// A max of zero or less removes no images. Custom images are not counted.
+func Evict(max int64) error {
+	es, orphans, err := entries()
+	if err != nil {
+		return err
+	}
+	for _, name := range orphans {
+		if err := os.Remove(path.Join(Dir(), name)); err != nil {
+			return err
+		}
+	}
+	if max <= 0 {
+		return nil
+	}
+
+	var total int64
+	for _, e := range es {
+		if !e.Custom {
+			total += e.Size
+		}
+	}
+	for _, e := range es {
+		if total <= max {
+			break
+		}
+		if e.Custom {
+			continue
+		}
+		if err := os.Remove(path.Join(Dir(), e.File)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		os.Remove(metaFile(Key(e.URL)))
+		removeThumbnails(e.File)
+		total -= e.Size
+	}
+	return nil
+}
+
+// Stats describes the contents of the image cache
+type Stats struct {
+	Path   string
+	Images int
+	Bytes  int64
+}
+
+// GetStats counts the cached images. An image cache which has not been
+// created yet is empty.
+func GetStats() (Stats, error) {
+	s := Stats{Path: Dir()}
+	es, _, err := entries()
+	if err != nil {
+		return s, err
+	}
+	for _, e := range es {
+		s.Images++
+		s.Bytes += e.Size
+	}
+	return s, nil
+}
+
 // exists returns whether or not a file or directory exists.
 func exists(name string) bool {
 	if _, err := os.Stat(name); err != nil {
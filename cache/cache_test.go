@@ -0,0 +1,360 @@
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// useTempDir caches images in a new temporary directory for one test
+func useTempDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "abv-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := conf
+	conf = viper.New()
+	conf.Set("configPath", dir)
+	conf.Set("imageRefresh", "1h")
+	conf.Set("thumbnailSizes", []int{16, 32})
+	conf.Set("thumbnailBackground", "#102030")
+	t.Cleanup(func() {
+		conf = old
+		os.RemoveAll(dir)
+	})
+}
+
+// pngBytes returns a PNG image of a single pixel of the given shade
+func pngBytes(t *testing.T, shade uint8) []byte {
+	img := image.NewGray(image.Rect(0, 0, 1, 1))
+	img.Set(0, 0, color.Gray{Y: shade})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// cachedBytes returns the contents of the cached image from url
+func cachedBytes(t *testing.T, url string) []byte {
+	e, ok := Lookup(url)
+	if !ok {
+		t.Fatalf("%s is not cached", url)
+	}
+	b, err := ioutil.ReadFile(path.Join(Dir(), e.File))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestImageSameFileNames(t *testing.T) {
+	useTempDir(t)
+	first, second := pngBytes(t, 0), pngBytes(t, 255)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/first/logo.png" {
+			w.Write(first)
+		} else {
+			w.Write(second)
+		}
+	}))
+	defer srv.Close()
+
+	for _, p := range []string{"/first/logo.png", "/second/logo.png"} {
+		if err := Image(srv.URL + p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !bytes.Equal(cachedBytes(t, srv.URL+"/first/logo.png"), first) {
+		t.Error("first logo was overwritten")
+	}
+	if !bytes.Equal(cachedBytes(t, srv.URL+"/second/logo.png"), second) {
+		t.Error("second logo was not cached")
+	}
+
+	e, _ := Lookup(srv.URL + "/first/logo.png")
+	if e.ContentType != "image/png" || e.URL != srv.URL+"/first/logo.png" || e.Size != int64(len(first)) {
+		t.Errorf("unexpected metadata %+v", e)
+	}
+	if s, err := GetStats(); err != nil || s.Images != 2 {
+		t.Errorf("GetStats() = %+v, %v, want 2 images", s, err)
+	}
+}
+
+func TestImageRejected(t *testing.T) {
+	useTempDir(t)
+	img := pngBytes(t, 0)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing.png":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write(img)
+		case "/page.png":
+			w.Write([]byte("<html><body>Not an image</body></html>"))
+		case "/slow.png":
+			time.Sleep(200 * time.Millisecond)
+			w.Write(img)
+		}
+	}))
+	defer srv.Close()
+
+	old := client
+	client = &http.Client{Timeout: 50 * time.Millisecond}
+	defer func() { client = old }()
+
+	for _, p := range []string{"/missing.png", "/page.png", "/slow.png"} {
+		if err := Image(srv.URL + p); err == nil {
+			t.Errorf("%s was cached", p)
+		}
+		if Cached(srv.URL + p) {
+			t.Errorf("%s is reported as cached", p)
+		}
+	}
+	files, _ := ioutil.ReadDir(Dir())
+	if len(files) != 0 {
+		t.Errorf("%d files were left in the cache", len(files))
+	}
+}
+
+func TestImageRefresh(t *testing.T) {
+	useTempDir(t)
+	img := pngBytes(t, 0)
+	requests, notModified := 0, 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write(img)
+	}))
+	defer srv.Close()
+	url := srv.URL + "/logo.png"
+
+	for i := 0; i < 2; i++ {
+		if err := Image(url); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if requests != 1 {
+		t.Fatalf("fresh image was fetched %d times", requests)
+	}
+
+	e, _ := Lookup(url)
+	if e.ETag != `"v1"` {
+		t.Errorf("ETag = %q", e.ETag)
+	}
+	e.Fetched = time.Now().Add(-2 * time.Hour)
+	if err := writeEntry(e); err != nil {
+		t.Fatal(err)
+	}
+	if Cached(url) {
+		t.Error("stale image is reported as cached")
+	}
+	if err := Image(url); err != nil {
+		t.Fatal(err)
+	}
+	if notModified != 1 || !Cached(url) {
+		t.Errorf("stale image was not revalidated")
+	}
+}
+
+func TestEvict(t *testing.T) {
+	useTempDir(t)
+	img := pngBytes(t, 0)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(img)
+	}))
+	defer srv.Close()
+
+	urls := []string{srv.URL + "/old.png", srv.URL + "/middle.png", srv.URL + "/new.png"}
+	for i, url := range urls {
+		if err := Image(url); err != nil {
+			t.Fatal(err)
+		}
+		e, _ := Lookup(url)
+		e.Fetched = time.Now().Add(time.Duration(i-3) * time.Minute)
+		writeEntry(e)
+	}
+	orphan := path.Join(Dir(), "logo.png")
+	if err := ioutil.WriteFile(orphan, img, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Evict(int64(2 * len(img))); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := Lookup(urls[0]); ok {
+		t.Error("least recently fetched image was not evicted")
+	}
+	for _, url := range urls[1:] {
+		if _, ok := Lookup(url); !ok {
+			t.Errorf("%s was evicted", url)
+		}
+	}
+	if exists(orphan) {
+		t.Error("image without metadata was not removed")
+	}
+	if exists(thumbnailPath(Key(urls[0])+".png", 16)) {
+		t.Error("thumbnail of evicted image was not removed")
+	}
+}
+
+// decodeThumbnail returns the thumbnail of the given size of a cached image file
+func decodeThumbnail(t *testing.T, file string, size int) image.Image {
+	name, err := Thumbnail(file, size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, format, err := image.Decode(f)
+	if err != nil || format != "jpeg" {
+		t.Fatalf("thumbnail is not a JPEG: %s, %v", format, err)
+	}
+	if b := img.Bounds(); b.Dx() != size || b.Dy() != size {
+		t.Fatalf("thumbnail is %dx%d, want %dx%d", b.Dx(), b.Dy(), size, size)
+	}
+	return img
+}
+
+// near returns whether two colors differ by no more than JPEG compression does
+func near(a, b color.Color) bool {
+	ar, ag, ab, _ := a.RGBA()
+	br, bg, bb, _ := b.RGBA()
+	d := func(x, y uint32) bool { return x>>8 <= y>>8+12 && y>>8 <= x>>8+12 }
+	return d(ar, br) && d(ag, bg) && d(ab, bb)
+}
+
+func TestThumbnail(t *testing.T) {
+	useTempDir(t)
+	// A wide logo, opaque white on the left and transparent on the right
+	logo := image.NewNRGBA(image.Rect(0, 0, 40, 20))
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 20; x++ {
+			logo.Set(x, y, color.White)
+		}
+	}
+	var buf bytes.Buffer
+	png.Encode(&buf, logo)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(buf.Bytes())
+	}))
+	defer srv.Close()
+
+	url := srv.URL + "/logo.png"
+	if err := Image(url); err != nil {
+		t.Fatal(err)
+	}
+	e, _ := Lookup(url)
+	for _, size := range Sizes() {
+		if !exists(thumbnailPath(e.File, size)) {
+			t.Errorf("thumbnail of size %d was not generated", size)
+		}
+	}
+
+	bg := color.RGBA{0x10, 0x20, 0x30, 0xff}
+	img := decodeThumbnail(t, e.File, 32)
+	for _, p := range []struct {
+		x, y int
+		want color.Color
+	}{
+		{2, 2, bg},           // above the logo
+		{4, 16, color.White}, // opaque half
+		{28, 16, bg},         // transparent half
+		{16, 29, bg},         // below the logo
+	} {
+		if c := img.At(p.x, p.y); !near(c, p.want) {
+			t.Errorf("pixel (%d, %d) is %v, want %v", p.x, p.y, c, p.want)
+		}
+	}
+
+	if c := decodeThumbnail(t, PlaceholderFile, 16).At(0, 0); !near(c, bg) {
+		t.Errorf("placeholder background is %v, want %v", c, bg)
+	}
+	if _, err := Thumbnail(e.File, 20); err == nil {
+		t.Error("thumbnail was made in a size which is not configured")
+	}
+	if _, err := Thumbnail("../"+e.File, 16); err == nil {
+		t.Error("thumbnail was made of a file outside the cache")
+	}
+}
+
+func TestCustomLogo(t *testing.T) {
+	useTempDir(t)
+	untappd, brand, drink := pngBytes(t, 0), pngBytes(t, 128), pngBytes(t, 255)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(untappd)
+	}))
+	defer srv.Close()
+	url := srv.URL + "/logo.png"
+
+	logo := func() []byte {
+		b, err := ioutil.ReadFile(path.Join(Dir(), LogoFile("100", "Brewery", url)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	if LogoFile("100", "Brewery", url) != "" {
+		t.Error("logo file of uncached logo is not empty")
+	}
+	if err := Image(url); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(logo(), untappd) {
+		t.Error("logo is not the image from the logo url")
+	}
+	if _, err := Save(BrandLogo("Brewery"), bytes.NewReader(brand)); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(logo(), brand) {
+		t.Error("custom brand logo does not take precedence over the logo url")
+	}
+	if _, err := Save(DrinkLogo("100"), bytes.NewReader(drink)); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(logo(), drink) {
+		t.Error("custom drink logo does not take precedence over the brand logo")
+	}
+
+	if err := Evict(1); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(logo(), drink) {
+		t.Error("custom logo was evicted")
+	}
+	if _, ok := Lookup(url); ok {
+		t.Error("logo from logo url was not evicted")
+	}
+
+	if err := Remove(DrinkLogo("100")); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(logo(), brand) {
+		t.Error("brand logo is not shown after removing the drink logo")
+	}
+	if err := Remove(DrinkLogo("100")); !os.IsNotExist(err) {
+		t.Errorf("removing a missing logo returned %v", err)
+	}
+	if _, err := Save(DrinkLogo("100"), bytes.NewReader([]byte("<html></html>"))); !errors.Is(err, ErrNotImage) {
+		t.Errorf("saving a page as a logo returned %v", err)
+	}
+}
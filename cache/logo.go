@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"image"
+	"io"
+	"os"
+	"path"
+)
+
+// DrinkLogo returns the name the custom logo of the drink with the given
+// barcode is cached under
+func DrinkLogo(barcode string) string {
+	return "custom:drink/" + barcode
+}
+
+// BrandLogo returns the name the custom logo of a brand is cached under. The
+// brand is the one stored in the database, before nicknames are applied.
+func BrandLogo(brand string) string {
+	return "custom:brand/" + brand
+}
+
+// LogoFile returns the File of the cached image shown as the logo of a drink:
+// the custom logo of the drink, else the custom logo of its stored brand, else
+// the image from its logo url. It returns an empty string if none are cached.
+func LogoFile(barcode, storedBrand, url string) string {
+	for _, name := range []string{DrinkLogo(barcode), BrandLogo(storedBrand), url} {
+		if name == "" {
+			continue
+		}
+		if e, ok := Lookup(name); ok {
+			return e.File
+		}
+	}
+	return ""
+}
+
+// Save caches a custom image read from r under a name such as DrinkLogo,
+// replacing any image cached under that name
+func Save(name string, r io.Reader) (Entry, error) {
+	old, cached := Lookup(name)
+	e := Entry{URL: name, Custom: true}
+	if err := store(&e, r); err != nil {
+		return e, err
+	}
+	if cached && old.File != e.File {
+		os.Remove(path.Join(Dir(), old.File))
+		removeThumbnails(old.File)
+	}
+	if err := thumbnails(e.File); err != nil && err != image.ErrFormat {
+		return e, err
+	}
+	return e, nil
+}
+
+// Remove removes the image cached under a name and its thumbnails. It returns
+// an error satisfying os.IsNotExist if no image is cached under the name.
+func Remove(name string) error {
+	e, err := readEntry(Key(name))
+	if err != nil {
+		return err
+	}
+	removeThumbnails(e.File)
+	if err := os.Remove(path.Join(Dir(), e.File)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.Remove(metaFile(Key(name)))
+}
@@ -0,0 +1,93 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// Prefetcher caches images in the background, so that slow image hosts never
+// hold up a request. Failed downloads are retried with an increasing delay.
+// Use NewPrefetcher to create a Prefetcher and Run to start it.
+type Prefetcher struct {
+	queue   chan job
+	retries int
+	delay   time.Duration
+
+	// OnError is called with each failed download, if not nil
+	OnError func(url string, err error, willRetry bool)
+
+	mu     sync.Mutex
+	queued map[string]bool
+}
+
+// job is an image to download, and the number of earlier attempts
+type job struct {
+	url     string
+	attempt int
+}
+
+// NewPrefetcher returns a Prefetcher which queues at most size images and
+// tries each one up to retries more times, waiting delay longer each time
+func NewPrefetcher(size, retries int, delay time.Duration) *Prefetcher {
+	return &Prefetcher{
+		queue:   make(chan job, size),
+		retries: retries,
+		delay:   delay,
+		queued:  make(map[string]bool),
+	}
+}
+
+// Enqueue queues an image to be cached without waiting. It returns false if
+// the image is already cached or queued, or if the queue is full.
+func (p *Prefetcher) Enqueue(url string) bool {
+	if url == "" || Cached(url) {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.queued[url] {
+		return false
+	}
+	select {
+	case p.queue <- job{url: url}:
+		p.queued[url] = true
+		return true
+	default:
+		return false
+	}
+}
+
+// Run caches queued images one at a time until the program exits
+func (p *Prefetcher) Run() {
+	for j := range p.queue {
+		err := Image(j.url)
+		if err == nil {
+			p.done(j.url)
+			continue
+		}
+
+		retry := j.attempt < p.retries
+		if p.OnError != nil {
+			p.OnError(j.url, err, retry)
+		}
+		if !retry {
+			p.done(j.url)
+			continue
+		}
+		j.attempt++
+		time.AfterFunc(time.Duration(j.attempt)*p.delay, func() {
+			select {
+			case p.queue <- j:
+			default:
+				p.done(j.url)
+			}
+		})
+	}
+}
+
+// done allows an image to be queued again
+func (p *Prefetcher) done(url string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.queued, url)
+}
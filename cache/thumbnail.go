@@ -0,0 +1,209 @@
+package cache
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	// Decoders of the cached image formats which can be thumbnailed
+	_ "image/gif"
+	_ "image/png"
+)
+
+// PlaceholderFile is the file name of the thumbnail shown for drinks without a
+// cached logo
+const PlaceholderFile = "placeholder.jpg"
+
+// Sizes returns the width and height in pixels of the thumbnails generated
+// for each cached image
+func Sizes() []int {
+	return conf.GetIntSlice("thumbnailSizes")
+}
+
+// validSize returns whether thumbnails of the given size are generated
+func validSize(size int) bool {
+	for _, s := range Sizes() {
+		if s == size {
+			return true
+		}
+	}
+	return false
+}
+
+// thumbnailPath returns the path of the thumbnail of the given size of a
+// cached image file
+func thumbnailPath(file string, size int) string {
+	name := strings.TrimSuffix(file, path.Ext(file)) + ".jpg"
+	return path.Join(Dir(), "thumbnails", strconv.Itoa(size), name)
+}
+
+// Thumbnail returns the path of a square JPEG thumbnail of the given size of a
+// cached image file, such as the File of an Entry, generating it if needed.
+// The image is scaled to fit and placed on the thumbnailBackground color, so
+// that transparent images look the same everywhere. PlaceholderFile is the
+// placeholder for drinks without a logo.
+func Thumbnail(file string, size int) (string, error) {
+	if !validSize(size) {
+		return "", fmt.Errorf("thumbnail size %d is not one of thumbnailSizes %v", size, Sizes())
+	}
+	if file != path.Base(file) || strings.HasPrefix(file, ".") {
+		return "", fmt.Errorf("invalid image file name %q", file)
+	}
+	thumb := thumbnailPath(file, size)
+	if exists(thumb) {
+		return thumb, nil
+	}
+
+	var img image.Image
+	if file == PlaceholderFile {
+		img = placeholder(size)
+	} else {
+		f, err := os.Open(path.Join(Dir(), file))
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		src, _, err := image.Decode(f)
+		if err != nil {
+			return "", err
+		}
+		img = fit(src, size, background())
+	}
+	return thumb, writeJPEG(thumb, img)
+}
+
+// thumbnails generates every configured thumbnail of a newly cached image
+// file, replacing any earlier thumbnails of the file
+func thumbnails(file string) error {
+	removeThumbnails(file)
+	for _, size := range Sizes() {
+		if _, err := Thumbnail(file, size); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeThumbnails removes the thumbnails of every size of a cached image file
+func removeThumbnails(file string) {
+	for _, size := range Sizes() {
+		os.Remove(thumbnailPath(file, size))
+	}
+}
+
+// writeJPEG writes an image to a temporary file and then moves it to name, so
+// that a partly written thumbnail is never served
+func writeJPEG(name string, img image.Image) error {
+	if err := os.MkdirAll(path.Dir(name), os.ModePerm); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(path.Dir(name), ".thumbnail-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if err = jpeg.Encode(f, img, &jpeg.Options{Quality: 85}); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), name)
+}
+
+// background returns the configured thumbnailBackground color, a hex color
+// like "#242324". Black is used if it is not valid.
+func background() color.RGBA {
+	s := strings.TrimPrefix(conf.GetString("thumbnailBackground"), "#")
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil || len(s) != 6 {
+		return color.RGBA{A: 0xff}
+	}
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}
+}
+
+// fit scales src to fit in a size by size square of the background color,
+// averaging the source pixels covered by each pixel of the thumbnail.
+func fit(src image.Image, size int, bg color.RGBA) *image.RGBA {
+	dst := square(size, bg)
+	b := src.Bounds()
+	if b.Empty() {
+		return dst
+	}
+	w, h := size, size
+	if b.Dx() > b.Dy() {
+		h = atLeastOne(size * b.Dy() / b.Dx())
+	} else {
+		w = atLeastOne(size * b.Dx() / b.Dy())
+	}
+	left, top := (size-w)/2, (size-h)/2
+
+	for y := 0; y < h; y++ {
+		y0 := b.Min.Y + y*b.Dy()/h
+		y1 := y0 + atLeastOne(b.Min.Y+(y+1)*b.Dy()/h-y0)
+		for x := 0; x < w; x++ {
+			x0 := b.Min.X + x*b.Dx()/w
+			x1 := x0 + atLeastOne(b.Min.X+(x+1)*b.Dx()/w-x0)
+
+			// Colors are alpha-premultiplied, so they are averaged and then
+			// composited over the background directly
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			r, g, bl, a = r/n, g/n, bl/n, a/n
+			over := func(c uint64, back uint8) uint8 {
+				return uint8((c + uint64(back)*0x101*(0xffff-a)/0xffff) >> 8)
+			}
+			dst.SetRGBA(left+x, top+y, color.RGBA{over(r, bg.R), over(g, bg.G), over(bl, bg.B), 0xff})
+		}
+	}
+	return dst
+}
+
+// square returns a size by size image of a single color
+func square(size int, c color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, c.A
+	}
+	return img
+}
+
+// placeholder draws an outline of a pint glass on the background color
+func placeholder(size int) *image.RGBA {
+	img := square(size, background())
+	glass := color.RGBA{R: 0x80, G: 0x80, B: 0x80, A: 0xff}
+
+	top, bottom := size/5, size*4/5
+	stroke := atLeastOne(size / 32)
+	for y := top; y < bottom; y++ {
+		// The glass narrows from 3/5 of the size at the top to 2/5 at the bottom
+		half := size*3/10 - (size/10)*(y-top)/(bottom-top)
+		left, right := size/2-half, size/2+half
+		for x := left; x < right; x++ {
+			if x < left+stroke || x >= right-stroke || y >= bottom-stroke {
+				img.SetRGBA(x, y, glass)
+			}
+		}
+	}
+	return img
+}
+
+func atLeastOne(n int) int {
+	if n < 1 {
+		return 1
+	}
+	return n
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// loadCommands builds the command barcode table from the [commandBarcodes]
+// config table and the legacy undoBarcode and redoBarcode settings.
+//
+// Scanning a command barcode performs its event on behalf of the scanner.
+func loadCommands(conf *viper.Viper) (map[string]Event, error) {
+	commands := make(map[string]Event)
+	bindings := conf.GetStringMapString("commandBarcodes")
+	legacy := map[string]string{"undo": "undoBarcode", "redo": "redoBarcode"}
+	for action, setting := range legacy {
+		if _, ok := bindings[action]; !ok && conf.GetString(setting) != "" {
+			bindings[action] = conf.GetString(setting)
+		}
+	}
+
+	actions := make(map[string]string)
+	for action, barcode := range bindings {
+		cmd, err := newCommand(action)
+		if err != nil {
+			return nil, err
+		}
+		if other, exists := actions[barcode]; exists {
+			return nil, fmt.Errorf("command barcode %s is used for both %q and %q", barcode, other, action)
+		}
+		actions[barcode] = action
+		commands[barcode] = cmd
+	}
+	return commands, nil
+}
+
+// newCommand returns the event for the named action.
+//
+// Actions are named like their keybindings, with the addition of
+// "quantity-N" to select an arbitrary number of drinks per scan,
+// "start-batch" and "end-batch" to group actions for undo, "void" to take
+// back the next drink scanned, "open-tab" and "close-tab" to group servings
+// into a tab, and "start-count" and "end-count" to count the inventory.
+func newCommand(action string) (Event, error) {
+	switch action {
+	case "undo":
+		return Event{Kind: undoEvent}, nil
+	case "redo":
+		return Event{Kind: redoEvent}, nil
+	case "stocking":
+		return Event{Kind: modeEvent, Mode: stocking}, nil
+	case "serving":
+		return Event{Kind: modeEvent, Mode: serving}, nil
+	case "start-batch":
+		return Event{Kind: startBatchEvent}, nil
+	case "end-batch":
+		return Event{Kind: endBatchEvent}, nil
+	case "void":
+		return Event{Kind: voidEvent}, nil
+	case "open-tab":
+		return Event{Kind: openTabEvent}, nil
+	case "close-tab":
+		return Event{Kind: closeTabEvent}, nil
+	case "start-count":
+		return Event{Kind: startCountEvent}, nil
+	case "end-count":
+		return Event{Kind: endCountEvent}, nil
+	}
+
+	if q, ok := quantityActions[action]; ok {
+		return Event{Kind: quantityEvent, Quantity: q}, nil
+	}
+	if strings.HasPrefix(action, "quantity-") {
+		q, err := strconv.Atoi(strings.TrimPrefix(action, "quantity-"))
+		if err != nil || q < 1 {
+			return Event{}, fmt.Errorf("invalid quantity in command barcode action %q", action)
+		}
+		return Event{Kind: quantityEvent, Quantity: q}, nil
+	}
+	return Event{}, fmt.Errorf("unknown command barcode action %q", action)
+}
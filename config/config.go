@@ -37,6 +37,22 @@ func newViper() (*viper.Viper, error) {
 	v.SetDefault("configPath", path.Join(home, ".abv"))
 	v.SetDefault("webRoot", path.Join("/srv", "http"))
 	v.SetDefault("apiUrl", "localhost")
+	v.SetDefault("maxServeQuantity", 6)
+	v.SetDefault("stationAddress", "localhost:8082")
+	v.SetDefault("stationUrl", "localhost:8082")
+	v.SetDefault("undoDepth", 100)
+	v.SetDefault("undoMaxAge", "12h")
+	v.SetDefault("undoIdleTimeout", "24h")
+	v.SetDefault("apiPublicMenu", true)
+	v.SetDefault("imageRefresh", "168h")
+	v.SetDefault("imageCacheSize", 100<<20)
+	v.SetDefault("thumbnailSizes", []int{128, 256})
+	v.SetDefault("thumbnailBackground", "#242324")
+	v.SetDefault("menu.layout", "grid")
+	v.SetDefault("menu.pageSize", 16)
+	v.SetDefault("menu.rotate", "15s")
+	v.SetDefault("menu.lowStock", 3)
+	v.SetDefault("menu.thumbnailSize", 128)
 
 	if err = v.ReadInConfig(); err != nil {
 		return nil, err
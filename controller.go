@@ -1,10 +1,18 @@
 package main
 
 import (
-	"errors"
+	"bytes"
+	"database/sql"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
 
+	"github.com/bhutch29/abv/cache"
 	"github.com/bhutch29/abv/model"
 	"github.com/bhutch29/abv/undo"
+	"github.com/sirupsen/logrus"
 )
 
 // Mode is an Enum of operating modes
@@ -16,51 +24,295 @@ const (
 	stocking      = "stocking"
 )
 
-// ModalController supports using the GUI via distinct behavioral modes
+// title returns the capitalized name of the mode.
+func (m Mode) title() string {
+	if m == "" {
+		return ""
+	}
+	return strings.ToUpper(string(m[:1])) + string(m[1:])
+}
+
+// ModalController supports using the GUI via distinct behavioral modes.
+//
+// All scanning, undo and mode changes are handled in order by a single
+// goroutine started with Run, so any number of input sources can safely
+// drive the same ModalController.
 type ModalController struct {
+	events chan Event
+
+	mu          sync.Mutex
+	subscribers []chan Result
+
+	// The following are only accessed by the Run goroutine
 	currentMode Mode
-	backend     model.Model
-	lastBarcode string
-	lastID      string
+	quantity    int
+	backend     model.Backend
 	actor       undo.Actor
+	scanners    map[string]*Scanner
+	commands    map[string]Event
+	voids       map[string]bool           // the next drink scanned is voided
+	tabs        map[string]*tab           // open tabs
+	counts      map[string]map[string]int // counted drinks by barcode
+	lastTab     int
 }
 
 // New creates a new fully initialized ModalController
-func New() (ModalController, error) {
-	m := ModalController{}
+func New() (*ModalController, error) {
+	m := &ModalController{}
 
+	m.events = make(chan Event, 16)
 	m.currentMode = serving
+	m.quantity = 1
+	m.voids = make(map[string]bool)
+	m.tabs = make(map[string]*tab)
+	m.counts = make(map[string]map[string]int)
 
-	backend, err := model.New()
+	backend, err := model.NewBackend()
 	if err != nil {
 		return m, err
 	}
 	m.backend = backend
 
+	// Actions against the API cannot share a transaction, so only the
+	// local database runs them atomically
 	a := undo.NewActor()
+	if db, ok := backend.(*model.Model); ok {
+		a = undo.NewTxActor(func() (undo.Tx, error) {
+			tx, err := db.Begin()
+			if err != nil {
+				return nil, err
+			}
+			return tx, nil
+		})
+	}
+	a.SetLimits(undo.Limits{
+		Depth:       conf.GetInt("undoDepth"),
+		MaxAge:      conf.GetDuration("undoMaxAge"),
+		IdleTimeout: conf.GetDuration("undoIdleTimeout"),
+	})
 	m.actor = a
 
+	scanners, err := loadScanners(conf)
+	if err != nil {
+		return m, err
+	}
+	m.scanners = scanners
+
+	commands, err := loadCommands(conf)
+	if err != nil {
+		return m, err
+	}
+	m.commands = commands
+
 	return m, nil
 }
 
-// GetMode returns the current operating Mode
-func (c *ModalController) GetMode() Mode {
+// Run handles events until the program exits
+func (c *ModalController) Run() {
+	for e := range c.events {
+		r := c.handle(e)
+		if e.reply != nil {
+			e.reply <- r
+		}
+		if e.Kind != statusEvent {
+			c.publish(r)
+		}
+	}
+}
+
+// Post queues an event without waiting for its result
+func (c *ModalController) Post(e Event) {
+	c.events <- e
+}
+
+// Do queues an event and waits for its result
+func (c *ModalController) Do(e Event) Result {
+	e.reply = make(chan Result, 1)
+	c.events <- e
+	return <-e.reply
+}
+
+// Status returns a snapshot of the current modes and quantities
+func (c *ModalController) Status() Status {
+	return c.Do(Event{Kind: statusEvent}).Status
+}
+
+// Subscribe returns a channel which receives the result of every event
+func (c *ModalController) Subscribe() <-chan Result {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan Result, 64)
+	c.subscribers = append(c.subscribers, ch)
+	return ch
+}
+
+// Unsubscribe stops sending results to a channel returned by Subscribe
+func (c *ModalController) Unsubscribe(ch <-chan Result) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, sub := range c.subscribers {
+		if sub == ch {
+			c.subscribers = append(c.subscribers[:i], c.subscribers[i+1:]...)
+			return
+		}
+	}
+}
+
+// publish sends a result to every subscriber. Subscribers which have fallen
+// too far behind miss the result rather than stalling the controller.
+func (c *ModalController) publish(r Result) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, ch := range c.subscribers {
+		select {
+		case ch <- r:
+		default:
+			logFile.Warn("Dropped result for a slow subscriber")
+		}
+	}
+}
+
+// handle performs a single event and returns its result.
+func (c *ModalController) handle(e Event) Result {
+	r := Result{Event: e}
+	if scope := c.scopeFor(e); e.allows != nil && !e.allows(scope) {
+		r.forbidden = true
+		r.fail("Not allowed without an API token with the ", scope, " scope")
+		r.Status = c.status()
+		return r
+	}
+	switch e.Kind {
+	case scanEvent:
+		c.handleScan(&r)
+	case modeEvent:
+		c.setMode(&r, e.ID, e.Mode)
+	case quantityEvent:
+		c.setQuantity(&r, e.ID, e.Quantity)
+	case undoEvent:
+		c.undo(&r, e.ID)
+	case redoEvent:
+		c.redo(&r, e.ID)
+	case newDrinkEvent:
+		c.newDrink(&r, e.ID, e.Drink)
+	case startBatchEvent:
+		c.startBatch(&r, e.ID)
+	case endBatchEvent:
+		c.endBatch(&r, e.ID)
+	case logoEvent:
+		c.setLogo(&r, e)
+		r.Event.Logo = nil
+	case voidEvent:
+		c.armVoid(&r, e.ID)
+	case openTabEvent:
+		c.openTab(&r, e.ID)
+	case closeTabEvent:
+		c.closeTab(&r, e.ID)
+	case startCountEvent:
+		c.startCount(&r, e.ID)
+	case endCountEvent:
+		c.endCount(&r, e.ID)
+	}
+	r.Status = c.status()
+	return r
+}
+
+// status returns a snapshot of the current modes and quantities. Scanners
+// are sorted by ID so that their order is stable between updates.
+func (c *ModalController) status() Status {
+	s := Status{Mode: c.currentMode, Quantity: c.quantity}
+	for _, scanner := range c.scanners {
+		s.Scanners = append(s.Scanners, *scanner)
+	}
+	sort.Slice(s.Scanners, func(i, j int) bool { return s.Scanners[i].ID < s.Scanners[j].ID })
+	for _, id := range c.actor.IDs() {
+		u := UndoStatus{ID: id}
+		if a := c.actor.NextUndo(id); a != nil {
+			u.Undo = undo.Describe(a)
+		}
+		if a := c.actor.NextRedo(id); a != nil {
+			u.Redo = undo.Describe(a)
+		}
+		history := c.actor.History(id)
+		for i := len(history) - 1; i >= 0 && !c.actor.InBatch(id); i-- {
+			if !history[i].Undone {
+				u.UndoTime = history[i].Time
+				break
+			}
+		}
+		if u.Undo != "" || u.Redo != "" {
+			s.Undo = append(s.Undo, u)
+		}
+	}
+	return s
+}
+
+// modeFor returns the operating Mode used for input from the given ID.
+func (c *ModalController) modeFor(id string) Mode {
+	if s := c.scanners[id]; s != nil {
+		return s.Mode
+	}
 	return c.currentMode
 }
 
-// SetMode changes the current operating Mode
-func (c *ModalController) SetMode(m Mode) {
-	c.currentMode = m
+// quantityFor returns the quantity of drinks per scan used for input from
+// the given ID.
+func (c *ModalController) quantityFor(id string) int {
+	if s := c.scanners[id]; s != nil {
+		return s.Quantity
+	}
+	return c.quantity
 }
 
-// LastBarcode returns the most recently cached barcode
-func (c *ModalController) LastBarcode() string {
-	return c.lastBarcode
+// setMode changes the operating Mode of the registered scanner with the
+// given ID, or the global Mode if the ID is not registered.
+//
+// Changing to serving mode resets the quantity of drinks per scan to 1.
+func (c *ModalController) setMode(r *Result, id string, m Mode) {
+	if c.modeFor(id) == m {
+		return
+	}
+
+	if s := c.scanners[id]; s != nil {
+		if s.Locked {
+			r.log(logrus.WarnLevel, "Scanner ", s, " is locked in ", s.Mode, " mode")
+			return
+		}
+		s.Mode = m
+		if m == serving {
+			s.Quantity = 1
+		}
+		r.log(logrus.InfoLevel, "Scanner ", s, " changed to ", m.title(), " Mode")
+		return
+	}
+
+	c.currentMode = m
+	if m == serving {
+		c.quantity = 1
+	}
+	r.log(logrus.InfoLevel, "Changed to ", m.title(), " Mode")
 }
 
-// LastID returns the most recently cached ID
-func (c *ModalController) LastID() string {
-	return c.lastID
+// setQuantity changes the quantity of drinks per scan of the registered
+// scanner with the given ID, or the global quantity if the ID is not registered.
+//
+// In serving mode, the quantity may not exceed the configured maxServeQuantity.
+func (c *ModalController) setQuantity(r *Result, id string, q int) {
+	if max := conf.GetInt("maxServeQuantity"); q > max && c.modeFor(id) != stocking {
+		r.log(logrus.WarnLevel, "Serving more than ", max, " drinks at once is not allowed")
+		return
+	}
+	if c.quantityFor(id) == q {
+		return
+	}
+
+	if s := c.scanners[id]; s != nil {
+		s.Quantity = q
+		r.log(logrus.InfoLevel, "Quantity of drinks per scan for scanner ", s, " changed to ", q)
+		return
+	}
+
+	c.quantity = q
+	r.log(logrus.InfoLevel, "Quantity of drinks per scan changed to ", q)
 }
 
 // GetInventory returns the currently stocked inventory with default sorting
@@ -81,132 +333,276 @@ func (c *ModalController) GetInventorySorted(sortFields []string) []model.Stocke
 	return result
 }
 
-// NewDrink stores a new drink to the database and increments the drink count
-func (c *ModalController) NewDrink(id string, d model.Drink, quantity int) error {
-	if c.currentMode != stocking {
-		return errors.New("NewDrink can only be called from stocking mode")
+// newDrink stores a new drink to the database and increments the drink count.
+func (c *ModalController) newDrink(r *Result, id string, d model.Drink) {
+	if c.modeFor(id) != stocking {
+		r.fail("New drinks can only be added in stocking mode")
+		return
 	}
 
-	logAllDebug("Parsed ID and Barcode:", "ID="+id, ", Barcode="+d.Barcode)
+	r.log(logrus.DebugLevel, "Parsed ID and Barcode: ID=", id, ", Barcode=", d.Barcode)
 
-	de := model.DrinkEntry{Barcode: d.Barcode, Quantity: quantity}
-	a := undo.NewCreateAndInputAction(d, de)
-	if err := c.actor.AddAction(id, a); err != nil {
-		return err
+	quantity := c.quantityFor(id)
+	de := model.DrinkEntry{Barcode: d.Barcode, Quantity: quantity, Scanner: id}
+	a := undo.NewCreateAndInputAction(c.backend, d, de)
+	if err := c.actor.AddAction(id, undo.WithLabel(describeEntry("new drink", de, d), a)); err != nil {
+		r.fail("Could not add new drink: ", err)
+		return
 	}
-	logAllInfo("Drink created and added to inventory!\n  #:     ", quantity, "\n  Name:  ", d.Name, "\n  Brand: ", d.Brand)
-	return nil
+	r.Changed = true
+	r.log(logrus.InfoLevel, "Drink created and added to inventory!\n  #:     ", quantity, "\n  Name:  ", d.Name, "\n  Brand: ", d.Brand, c.scannerLine(id))
 }
 
-// HandleBarcode inputs/outputs a drink and returns true if the barcode already exists or returns false if the barcode does not exist
-func (c *ModalController) HandleBarcode(id string, bc string, quantity int) (bool, error) {
-	c.lastBarcode = bc
-	c.lastID = id
+// handleScan handles a scanned barcode as either a command barcode or a drink.
+//
+// Unrecognized barcodes are marked as Unknown in stocking mode, so a user
+// interface can prompt for the new drink's information.
+func (c *ModalController) handleScan(r *Result) {
+	id, bc := r.Event.ID, r.Event.Barcode
+
+	if cmd, ok := c.commands[bc]; ok {
+		r.log(logrus.DebugLevel, "Scanned command barcode: ", bc)
+		cmd.ID = id
+		result := c.handle(cmd)
+		result.Messages = append(r.Messages, result.Messages...)
+		*r = result
+		return
+	}
+
+	r.log(logrus.DebugLevel, "Scanned barcode: ", bc, c.prettyID(id))
 	exists, err := c.backend.BarcodeExists(bc)
 	if err != nil {
-		return false, err
+		r.fail("Failed to search database for barcode: ", err)
+		return
+	}
+	switch {
+	case exists && c.counts[id] != nil:
+		c.countDrink(r, id, bc)
+		return
+	case exists && c.voids[id]:
+		c.voidDrink(r, id, bc)
+		return
+	case exists:
+		c.handleDrink(r, id, bc, c.quantityFor(id))
+		return
+	case c.counts[id] != nil:
+		r.log(logrus.WarnLevel, "Barcode not recognized while counting. Drink will not be counted")
+		return
+	case c.voids[id]:
+		delete(c.voids, id)
+		r.log(logrus.WarnLevel, "Barcode not recognized. Nothing was voided")
+		return
 	}
-	if exists {
-		logFile.Info("Known barcode scanned: ", bc)
-		c.handleDrink(id, bc, quantity)
-		return true, nil
+
+	if c.modeFor(id) != stocking {
+		r.log(logrus.WarnLevel, "Barcode not recognized while serving. Drink will not be recorded")
+		return
 	}
-	return false, nil
+	r.Unknown = true
 }
 
 // handleDrink calls either the modal controller's input or output drink
 // methods for the given input device and settings, depending on whether
-// the current mode is stocking or serving.
-func (c *ModalController) handleDrink(id string, bc string, quantity int) {
-	d := model.DrinkEntry{Barcode: bc, Quantity: quantity}
+// the input device's mode is stocking or serving.
+func (c *ModalController) handleDrink(r *Result, id string, bc string, quantity int) {
+	d := model.DrinkEntry{Barcode: bc, Quantity: quantity, Scanner: id}
 
 	drink, err := c.backend.GetDrinkByBarcode(d.Barcode)
 	if err != nil {
-		logAllError("Error creating drink. Could not get Drink information from barcode: ", err)
+		r.log(logrus.ErrorLevel, "Error creating drink. Could not get Drink information from barcode: ", err)
 	}
 
-	if c.currentMode == stocking {
-		c.inputDrinks(id, d, drink)
-	} else if c.currentMode == serving {
+	mode := c.modeFor(id)
+	if mode == stocking {
+		c.inputDrinks(r, id, d, drink)
+	} else if mode == serving {
 		count, err := c.backend.GetCountByBarcode(d.Barcode)
 		if err != nil {
-			logAllError("Could not get count by barcode: ", err)
+			r.fail("Could not get count by barcode: ", err)
 			return
 		}
 		if count <= 0 {
-			logAllWarn("That drink was not in the inventory!\n  Name:  ", drink.Name, "\n  Brand: ", drink.Brand)
+			r.log(logrus.WarnLevel, "That drink was not in the inventory!\n  Name:  ", drink.Name, "\n  Brand: ", drink.Brand)
 			return
 		}
-		c.outputDrinks(id, d, drink)
+		if count < quantity {
+			r.log(logrus.WarnLevel, "Only ", count, " of that drink left in the inventory!\n  Name:  ", drink.Name, "\n  Brand: ", drink.Brand)
+			return
+		}
+		c.outputDrinks(r, id, d, drink)
 	}
 }
 
 // outputDrinks handles the removing of a drink from inventory.
-func (c *ModalController) outputDrinks(id string, de model.DrinkEntry, d model.Drink) {
-	a := undo.NewOutputDrinksAction(de)
-	logAllDebug("Adding action with id = ", id)
-	if err := c.actor.AddAction(id, a); err != nil {
-		logAllError("Could not remove drink from inventory: ", err)
-	} else {
-		count, err := c.backend.GetCountByBarcode(d.Barcode)
-		if err != nil {
-			logAllError("Could not get count by barcode: ", err)
-			return
-		}
-		logAllInfo("Drink removed from inventory!\n  Name:  ", d.Name, "\n  Brand: ", d.Brand, "\n  Remaining: ", count)
+func (c *ModalController) outputDrinks(r *Result, id string, de model.DrinkEntry, d model.Drink) {
+	a := undo.NewOutputDrinksAction(c.backend, de)
+	r.log(logrus.DebugLevel, "Adding action with id = ", id)
+	if err := c.actor.AddAction(id, undo.WithLabel(describeEntry("serving", de, d), a)); err != nil {
+		r.fail("Could not remove drink from inventory: ", err)
+		return
+	}
+	r.Changed = true
+	if t := c.tabs[id]; t != nil {
+		t.servings[a] = true
 	}
+	count, err := c.backend.GetCountByBarcode(d.Barcode)
+	if err != nil {
+		r.fail("Could not get count by barcode: ", err)
+		return
+	}
+	r.log(logrus.InfoLevel, "Drink removed from inventory!\n  #:     ", de.Quantity, "\n  Name:  ", d.Name, "\n  Brand: ", d.Brand, "\n  Remaining: ", count, c.scannerLine(id))
 }
 
 // inputDrinks handles the adding of a drink to inventory.
-func (c *ModalController) inputDrinks(id string, de model.DrinkEntry, d model.Drink) {
-	a := undo.NewInputDrinksAction(de)
-	logAllDebug("Adding action with id = ", id)
-	if err := c.actor.AddAction(id, a); err != nil {
-		logAllError("Could not add drink to inventory: ", err)
-	} else {
-		logAllInfo("Drink added to inventory!\n  #:     ", quantity, "\n  Name:  ", d.Name, "\n  Brand: ", d.Brand)
+func (c *ModalController) inputDrinks(r *Result, id string, de model.DrinkEntry, d model.Drink) {
+	a := undo.NewInputDrinksAction(c.backend, de)
+	r.log(logrus.DebugLevel, "Adding action with id = ", id)
+	if err := c.actor.AddAction(id, undo.WithLabel(describeEntry("stocking", de, d), a)); err != nil {
+		r.fail("Could not add drink to inventory: ", err)
+		return
 	}
+	r.Changed = true
+	r.log(logrus.InfoLevel, "Drink added to inventory!\n  #:     ", de.Quantity, "\n  Name:  ", d.Name, "\n  Brand: ", d.Brand, c.scannerLine(id))
 }
 
-// ClearInputOutputRecords wipes out all stocking and serving records
-func (c *ModalController) ClearInputOutputRecords() error {
-	if err := c.backend.ClearInputTable(); err != nil {
-		return err
+// describeEntry returns the undo label of a drink entry, such as
+// "serving of 2 × Name".
+func describeEntry(what string, de model.DrinkEntry, d model.Drink) string {
+	name := d.Name
+	if name == "" {
+		name = de.Barcode
 	}
-	err := c.backend.ClearOutputTable()
-	return err
+	return fmt.Sprintf("%s of %d × %s", what, de.Quantity, name)
 }
 
-// Undo reverts the previous action with the given id, if any
-func (c *ModalController) Undo(id string) {
+// undo reverts the previous action with the given id, if any. Undoing while
+// a tab is open reverts the whole tab.
+func (c *ModalController) undo(r *Result, id string) {
+	if t := c.tabs[id]; t != nil {
+		delete(c.tabs, id)
+		r.log(logrus.InfoLevel, "Closed ", t, " to undo it")
+	}
+	label := undo.Describe(c.actor.NextUndo(id))
 	acted, err := c.actor.Undo(id)
 	if err != nil {
-		logAllError("Could not undo last action with id = "+id, err)
+		r.fail("Could not undo "+label+" with id = "+id+": ", err)
 	}
 	if acted {
-		logAllInfo("Reverted last action" + c.prettyID(id))
+		r.Changed = true
+		r.log(logrus.InfoLevel, "Reverted "+label+c.prettyID(id))
 	}
 }
 
-// Redo reruns the previously reverted action with the given id, if any
-func (c *ModalController) Redo(id string) {
+// redo reruns the previously reverted action with the given id, if any.
+func (c *ModalController) redo(r *Result, id string) {
+	label := undo.Describe(c.actor.NextRedo(id))
 	acted, err := c.actor.Redo(id)
 	if err != nil {
-		logAllError("Could not redo last action with id = "+id, err)
+		r.fail("Could not redo "+label+" with id = "+id+": ", err)
 	}
 	if acted {
-		logAllInfo("Redid last action" + c.prettyID(id))
+		r.Changed = true
+		r.log(logrus.InfoLevel, "Redid "+label+c.prettyID(id))
 	}
 }
 
+// startBatch groups the following actions with the given id so that a single
+// undo reverts all of them.
+func (c *ModalController) startBatch(r *Result, id string) {
+	label := string(c.modeFor(id)) + " session"
+	if !c.actor.StartBatch(id, label) {
+		r.log(logrus.WarnLevel, "A batch is already in progress"+c.prettyID(id))
+		return
+	}
+	r.log(logrus.InfoLevel, "Started "+label+c.prettyID(id)+". Everything until the end of the batch is undone together")
+}
+
+// endBatch ends the batch of actions with the given id, closing its tab if
+// it is one.
+func (c *ModalController) endBatch(r *Result, id string) {
+	if c.tabs[id] != nil {
+		c.closeTab(r, id)
+		return
+	}
+	b := c.actor.EndBatch(id)
+	if b == nil {
+		r.log(logrus.WarnLevel, "No batch is in progress"+c.prettyID(id))
+		return
+	}
+	r.log(logrus.InfoLevel, "Ended "+b.Label()+" of ", b.Len(), " actions"+c.prettyID(id))
+}
+
+// setLogo sets or removes the custom logo of the drink with the event's
+// barcode, or of its brand. Custom logos are stored in the image cache, or
+// uploaded to the API when the backend is the API.
+func (c *ModalController) setLogo(r *Result, e Event) {
+	d, err := c.backend.GetDrinkByBarcode(e.Barcode)
+	if err == sql.ErrNoRows {
+		r.fail("Barcode not recognized: ", e.Barcode)
+		return
+	}
+	if err != nil {
+		r.fail("Could not get drink information from barcode: ", err)
+		return
+	}
+
+	what, name := d.Brand+" "+d.Name, cache.DrinkLogo(d.Barcode)
+	if e.ForBrand {
+		what, name = d.Brand, cache.BrandLogo(d.StoredBrand)
+	}
+	client, remote := c.backend.(*model.Client)
+	switch {
+	case e.Logo == nil && remote && e.ForBrand:
+		err = client.DeleteBrandLogo(d.StoredBrand)
+	case e.Logo == nil && remote:
+		err = client.DeleteDrinkLogo(d.Barcode)
+	case e.Logo == nil:
+		err = cache.Remove(name)
+	case remote && e.ForBrand:
+		err = client.SetBrandLogo(d.StoredBrand, bytes.NewReader(e.Logo))
+	case remote:
+		err = client.SetDrinkLogo(d.Barcode, bytes.NewReader(e.Logo))
+	default:
+		_, err = cache.Save(name, bytes.NewReader(e.Logo))
+	}
+
+	if e.Logo == nil && (os.IsNotExist(err) || err == sql.ErrNoRows) {
+		r.log(logrus.WarnLevel, what, " has no custom logo")
+		return
+	}
+	if err != nil {
+		r.fail("Could not change the logo of ", what, ": ", err)
+		return
+	}
+	if e.Logo == nil {
+		r.log(logrus.InfoLevel, "Removed the custom logo of ", what)
+		return
+	}
+	r.log(logrus.InfoLevel, "Set the custom logo of ", what)
+}
+
 // prettyID returns a human readable message with the input device ID.
 func (c *ModalController) prettyID(id string) string {
 	if id == "" {
 		return ""
 	}
+	if s := c.scanners[id]; s != nil {
+		return " from scanner " + s.String()
+	}
 	return " with id = " + id
 }
 
+// scannerLine returns an indented log line naming the input device, if any.
+func (c *ModalController) scannerLine(id string) string {
+	if id == "" {
+		return ""
+	}
+	if s := c.scanners[id]; s != nil {
+		return "\n  Scanner: " + s.String()
+	}
+	return "\n  Scanner: " + id
+}
+
 // GetInventoryTotalQuantity returns the total number of beer bottles in stock
 func (c *ModalController) GetInventoryTotalQuantity() int {
 	q, err := c.backend.GetInventoryTotalQuantity()
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/bhutch29/abv/model"
+	"github.com/bhutch29/abv/undo"
+	"github.com/spf13/viper"
+)
+
+// Command barcodes of the test controller
+const (
+	voidBarcode       = "900"
+	openTabBarcode    = "901"
+	closeTabBarcode   = "902"
+	startCountBarcode = "903"
+	endCountBarcode   = "904"
+	stockingBarcode   = "905"
+)
+
+// newTestController returns a controller using an in-memory database with
+// 10 of drink "1" and 5 of drink "2" in stock
+func newTestController(t *testing.T) (*ModalController, *model.Model) {
+	logFile.Out = ioutil.Discard
+	conf = viper.New()
+	conf.Set("commandBarcodes", map[string]string{
+		"void":        voidBarcode,
+		"open-tab":    openTabBarcode,
+		"close-tab":   closeTabBarcode,
+		"start-count": startCountBarcode,
+		"end-count":   endCountBarcode,
+		"stocking":    stockingBarcode,
+	})
+
+	db, err := model.Open(":memory:", conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	for bc, quantity := range map[string]int{"1": 10, "2": 5} {
+		if _, err := db.CreateDrink(model.Drink{Barcode: bc, Name: "Drink " + bc}); err != nil {
+			t.Fatal(err)
+		}
+		db.InputDrinks(model.DrinkEntry{Barcode: bc, Quantity: quantity})
+	}
+
+	commands, err := loadCommands(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &ModalController{
+		currentMode: serving,
+		quantity:    1,
+		backend:     &db,
+		actor: undo.NewTxActor(func() (undo.Tx, error) {
+			tx, err := db.Begin()
+			if err != nil {
+				return nil, err
+			}
+			return tx, nil
+		}),
+		scanners: make(map[string]*Scanner),
+		commands: commands,
+		voids:    make(map[string]bool),
+		tabs:     make(map[string]*tab),
+		counts:   make(map[string]map[string]int),
+	}
+	return c, &db
+}
+
+// scan handles the scans of the given barcodes from the input device with
+// the given ID and returns the result of the last one
+func scan(c *ModalController, id string, barcodes ...string) Result {
+	var r Result
+	for _, bc := range barcodes {
+		r = c.handle(Event{Kind: scanEvent, ID: id, Barcode: bc})
+	}
+	return r
+}
+
+func checkCount(t *testing.T, m *model.Model, bc string, want int) {
+	t.Helper()
+	count, err := m.GetCountByBarcode(bc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != want {
+		t.Errorf("count of %s = %d, want %d", bc, count, want)
+	}
+}
+
+func checkMessage(t *testing.T, r Result, want string) {
+	t.Helper()
+	for _, m := range r.Messages {
+		if strings.Contains(m.Text, want) {
+			return
+		}
+	}
+	t.Errorf("no message containing %q in %+v", want, r.Messages)
+}
+
+func TestVoidServing(t *testing.T) {
+	c, m := newTestController(t)
+	scan(c, "a", "1", "1")
+	scan(c, "b", "1")
+	checkCount(t, m, "1", 7)
+
+	scan(c, "a", voidBarcode, "1")
+	checkCount(t, m, "1", 8)
+	scan(c, "a", voidBarcode, "1")
+	checkCount(t, m, "1", 9)
+
+	// Only the servings of the scanner itself can be voided
+	r := scan(c, "a", voidBarcode, "1")
+	checkMessage(t, r, "No serving of that drink to void")
+	checkCount(t, m, "1", 9)
+	r = scan(c, "a", voidBarcode, "2")
+	checkMessage(t, r, "No serving of that drink to void")
+	checkCount(t, m, "2", 5)
+
+	c.handle(Event{Kind: undoEvent, ID: "a"})
+	checkCount(t, m, "1", 8)
+	scan(c, "a", voidBarcode, "1")
+	checkCount(t, m, "1", 9)
+}
+
+func TestVoidStocking(t *testing.T) {
+	c, m := newTestController(t)
+	c.handle(Event{Kind: modeEvent, ID: "a", Mode: stocking})
+	scan(c, "a", "2")
+	checkCount(t, m, "2", 6)
+	scan(c, "a", voidBarcode, "2")
+	checkCount(t, m, "2", 5)
+	r := scan(c, "a", voidBarcode, "2")
+	checkMessage(t, r, "No stocking of that drink to void")
+	checkCount(t, m, "2", 5)
+}
+
+func TestTab(t *testing.T) {
+	c, m := newTestController(t)
+	r := scan(c, "a", openTabBarcode)
+	checkMessage(t, r, "Opened tab 1")
+	scan(c, "a", "1", "1", "2", voidBarcode, "1")
+	r = scan(c, "a", closeTabBarcode)
+	checkMessage(t, r, "Closed tab 1 of 2 drinks")
+	checkCount(t, m, "1", 9)
+	checkCount(t, m, "2", 4)
+
+	c.handle(Event{Kind: undoEvent, ID: "a"})
+	checkCount(t, m, "1", 10)
+	checkCount(t, m, "2", 5)
+
+	r = scan(c, "a", closeTabBarcode)
+	checkMessage(t, r, "No tab is open")
+	c.handle(Event{Kind: modeEvent, ID: "a", Mode: stocking})
+	r = scan(c, "a", openTabBarcode)
+	checkMessage(t, r, "Tabs can only be opened in serving mode")
+}
+
+func TestUndoOpenTab(t *testing.T) {
+	c, m := newTestController(t)
+	scan(c, "a", "2", openTabBarcode, "1", "1")
+	r := c.handle(Event{Kind: undoEvent, ID: "a"})
+	checkMessage(t, r, "Closed tab 1 to undo it")
+	checkCount(t, m, "1", 10)
+	checkCount(t, m, "2", 4)
+	if c.tabs["a"] != nil {
+		t.Error("tab is still open after undo")
+	}
+}
+
+func TestCount(t *testing.T) {
+	c, m := newTestController(t)
+	scan(c, "a", startCountBarcode, "1", "1", "1", "2", voidBarcode, "2")
+	r := scan(c, "a", startCountBarcode)
+	checkMessage(t, r, "A count is already in progress")
+	checkCount(t, m, "1", 10)
+
+	r = scan(c, "a", endCountBarcode)
+	checkMessage(t, r, "Corrected Drink 1 from 10 to 3")
+	checkMessage(t, r, "Corrected Drink 2 from 5 to 0")
+	checkCount(t, m, "1", 3)
+	checkCount(t, m, "2", 0)
+
+	// Corrections are not recorded as stocking
+	history, err := m.GetHistoryByBarcode("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tr := range history {
+		if tr.Quantity < 0 && tr.Kind != "correction" {
+			t.Errorf("negative %s of %d", tr.Kind, tr.Quantity)
+		}
+	}
+
+	c.handle(Event{Kind: undoEvent, ID: "a"})
+	checkCount(t, m, "1", 10)
+	checkCount(t, m, "2", 5)
+
+	r = scan(c, "a", endCountBarcode)
+	checkMessage(t, r, "No count is in progress")
+}
+
+func TestScopeFor(t *testing.T) {
+	c, m := newTestController(t)
+	serveOnly := func(scope string) bool { return scope == model.ScopeServe }
+	tests := []struct {
+		event     Event
+		forbidden bool
+	}{
+		{Event{Kind: scanEvent, ID: "a", Barcode: "1"}, false},
+		{Event{Kind: scanEvent, ID: "a", Barcode: voidBarcode}, false},
+		{Event{Kind: scanEvent, ID: "a", Barcode: "1"}, false},
+		{Event{Kind: scanEvent, ID: "a", Barcode: openTabBarcode}, false},
+		{Event{Kind: scanEvent, ID: "a", Barcode: closeTabBarcode}, false},
+		{Event{Kind: undoEvent, ID: "a"}, false},
+		{Event{Kind: modeEvent, ID: "a", Mode: serving}, false},
+		{Event{Kind: modeEvent, ID: "a", Mode: stocking}, true},
+		{Event{Kind: scanEvent, ID: "a", Barcode: stockingBarcode}, true},
+		{Event{Kind: scanEvent, ID: "a", Barcode: startCountBarcode}, true},
+		{Event{Kind: scanEvent, ID: "a", Barcode: endCountBarcode}, true},
+		{Event{Kind: newDrinkEvent, ID: "a", Drink: model.Drink{Barcode: "3"}}, true},
+	}
+	for _, tt := range tests {
+		tt.event.allows = serveOnly
+		r := c.handle(tt.event)
+		if r.forbidden != tt.forbidden {
+			t.Errorf("%+v: forbidden = %v, want %v", tt.event, r.forbidden, tt.forbidden)
+		}
+	}
+	// The serving was voided and the undo reverted the void
+	checkCount(t, m, "1", 9)
+
+	// Everything needs the stock scope while stocking or counting
+	c.scanners["b"] = &Scanner{ID: "b", Mode: stocking, Quantity: 1}
+	c.scanners["c"] = &Scanner{ID: "c", Mode: serving, Quantity: 1}
+	c.handle(Event{Kind: scanEvent, ID: "c", Barcode: startCountBarcode})
+	for _, id := range []string{"b", "c"} {
+		for _, e := range []Event{
+			{Kind: scanEvent, ID: id, Barcode: "1"},
+			{Kind: scanEvent, ID: id, Barcode: voidBarcode},
+			{Kind: undoEvent, ID: id},
+		} {
+			e.allows = serveOnly
+			if r := c.handle(e); !r.forbidden {
+				t.Errorf("%+v was allowed with the serve scope", e)
+			}
+		}
+	}
+}
+
+func TestStatusScannersAreSorted(t *testing.T) {
+	c, _ := newTestController(t)
+	for _, id := range []string{"c", "a", "d", "b"} {
+		c.scanners[id] = &Scanner{ID: id, Mode: serving, Quantity: 1}
+	}
+	var ids []string
+	for _, s := range c.status().Scanners {
+		ids = append(ids, s.ID)
+	}
+	if strings.Join(ids, ",") != "a,b,c,d" {
+		t.Errorf("scanners = %v, want them sorted by ID", ids)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bhutch29/abv/model"
+	"github.com/bhutch29/abv/undo"
+	"github.com/sirupsen/logrus"
+)
+
+// startCount starts counting the inventory with the input device with the
+// given ID. Drinks it scans are counted instead of stocked or served until
+// the count is ended.
+func (c *ModalController) startCount(r *Result, id string) {
+	if c.counts[id] != nil {
+		r.log(logrus.WarnLevel, "A count is already in progress"+c.prettyID(id))
+		return
+	}
+	c.counts[id] = make(map[string]int)
+	r.log(logrus.InfoLevel, "Started counting"+c.prettyID(id)+". Drinks scanned until the end of the count are counted instead of stocked or served")
+}
+
+// countDrink adds a scanned drink to the count, or takes it back out if a
+// void was armed.
+func (c *ModalController) countDrink(r *Result, id string, bc string) {
+	drink, err := c.backend.GetDrinkByBarcode(bc)
+	if err != nil {
+		r.log(logrus.ErrorLevel, "Could not get Drink information from barcode: ", err)
+	}
+
+	counted := c.counts[id]
+	quantity := c.quantityFor(id)
+	if c.voids[id] {
+		delete(c.voids, id)
+		if quantity > counted[bc] {
+			quantity = counted[bc]
+		}
+		quantity = -quantity
+	}
+	counted[bc] += quantity
+	r.log(logrus.InfoLevel, "Drink counted!\n  #:     ", quantity, "\n  Name:  ", drink.Name, "\n  Brand: ", drink.Brand, "\n  Counted: ", counted[bc], c.scannerLine(id))
+}
+
+// endCount ends the count of the input device with the given ID and corrects
+// the inventory of every counted drink to the number counted. Drinks which
+// were not scanned keep their quantity. The corrections are undone together.
+func (c *ModalController) endCount(r *Result, id string) {
+	counted := c.counts[id]
+	if counted == nil {
+		r.log(logrus.WarnLevel, "No count is in progress"+c.prettyID(id))
+		return
+	}
+	delete(c.counts, id)
+
+	var barcodes []string
+	for bc := range counted {
+		barcodes = append(barcodes, bc)
+	}
+	sort.Strings(barcodes)
+
+	started := c.actor.StartBatch(id, fmt.Sprintf("count of %d drinks", len(barcodes)))
+	corrected := 0
+	for _, bc := range barcodes {
+		current, err := c.backend.GetCountByBarcode(bc)
+		if err != nil {
+			r.fail("Could not get count by barcode: ", err)
+			break
+		}
+		if current == counted[bc] {
+			continue
+		}
+		drink, _ := c.backend.GetDrinkByBarcode(bc)
+		de := model.DrinkEntry{Barcode: bc, Quantity: counted[bc] - current, Scanner: id}
+		a := undo.NewCorrectDrinksAction(c.backend, de)
+		if err := c.actor.AddAction(id, undo.WithLabel(describeEntry("count correction", de, drink), a)); err != nil {
+			r.fail("Could not correct the inventory of ", bc, ": ", err)
+			break
+		}
+		corrected++
+		r.log(logrus.InfoLevel, "Corrected ", strings.TrimSpace(drink.Brand+" "+drink.Name), " from ", current, " to ", counted[bc])
+	}
+	if started {
+		c.actor.EndBatch(id)
+	}
+	if corrected > 0 {
+		r.Changed = true
+	}
+	r.log(logrus.InfoLevel, "Ended count of ", len(barcodes), " drinks", c.prettyID(id), ", correcting ", corrected)
+}
@@ -0,0 +1,95 @@
+// Package device reads barcodes directly from scanners attached to the host,
+// rather than relying on scanners typing into the user interface as a keyboard.
+package device
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Types of supported input devices.
+const (
+	Evdev  = "evdev"
+	Serial = "serial"
+)
+
+// retryDelay is how long to wait before reopening a device that failed,
+// such as a scanner that was unplugged.
+const retryDelay = 5 * time.Second
+
+// Device describes a barcode scanner attached to the host.
+type Device struct {
+	ID   string // ID attached to every Scan from this device
+	Path string // e.g. /dev/input/by-id/...-event-kbd or /dev/ttyACM0
+	Type string // Evdev or Serial
+	Baud int    // Serial devices only
+}
+
+// Scan is a barcode read from a Device.
+type Scan struct {
+	ID      string
+	Barcode string
+}
+
+// Listen starts reading every device in its own goroutine. Barcodes are sent
+// to scans and read errors are sent to errs. A device that fails is reopened
+// after a delay, so scanners can be unplugged and reconnected.
+func Listen(devices []Device, scans chan<- Scan, errs chan<- error) error {
+	for _, d := range devices {
+		if d.Type != Evdev && d.Type != Serial {
+			return fmt.Errorf("device %s has unknown type %q", d.Path, d.Type)
+		}
+	}
+	for _, d := range devices {
+		go listen(d, scans, errs)
+	}
+	return nil
+}
+
+// listen reads from a single device until the program exits.
+func listen(d Device, scans chan<- Scan, errs chan<- error) {
+	for {
+		var err error
+		switch d.Type {
+		case Evdev:
+			err = readEvdev(d, scans)
+		case Serial:
+			err = readSerial(d, scans)
+		}
+		errs <- fmt.Errorf("reading barcodes from %s: %v", d.Path, err)
+		time.Sleep(retryDelay)
+	}
+}
+
+// readLines sends every non-empty line read from r to scans. Lines may be
+// terminated by either a carriage return or a line feed.
+func readLines(d Device, r io.Reader, scans chan<- Scan) error {
+	s := bufio.NewScanner(r)
+	s.Split(scanLines)
+	for s.Scan() {
+		if line := s.Text(); line != "" {
+			scans <- Scan{ID: d.ID, Barcode: line}
+		}
+	}
+	if err := s.Err(); err != nil {
+		return err
+	}
+	return io.EOF
+}
+
+// scanLines is a bufio.SplitFunc which splits on either '\r' or '\n'.
+func scanLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
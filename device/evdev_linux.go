@@ -0,0 +1,110 @@
+//go:build linux
+// +build linux
+
+package device
+
+import (
+	"encoding/binary"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// Linux input event constants from linux/input.h and linux/input-event-codes.h.
+const (
+	eviocgrab = 0x40044590
+	evKey     = 0x01
+
+	keyRelease = 0
+	keyPress   = 1
+	keyRepeat  = 2
+
+	keyEnter   = 28
+	keyLShift  = 42
+	keyRShift  = 54
+	keyKPEnter = 96
+)
+
+// inputEvent mirrors struct input_event.
+type inputEvent struct {
+	Time  syscall.Timeval
+	Type  uint16
+	Code  uint16
+	Value int32
+}
+
+// keymap maps US keyboard key codes to their unshifted and shifted characters.
+var keymap = map[uint16][2]rune{
+	2: {'1', '!'}, 3: {'2', '@'}, 4: {'3', '#'}, 5: {'4', '$'}, 6: {'5', '%'},
+	7: {'6', '^'}, 8: {'7', '&'}, 9: {'8', '*'}, 10: {'9', '('}, 11: {'0', ')'},
+	12: {'-', '_'}, 13: {'=', '+'}, 26: {'[', '{'}, 27: {']', '}'},
+	39: {';', ':'}, 40: {'\'', '"'}, 41: {'`', '~'}, 43: {'\\', '|'},
+	51: {',', '<'}, 52: {'.', '>'}, 53: {'/', '?'}, 57: {' ', ' '},
+	71: {'7', '7'}, 72: {'8', '8'}, 73: {'9', '9'}, 74: {'-', '-'},
+	75: {'4', '4'}, 76: {'5', '5'}, 77: {'6', '6'}, 78: {'+', '+'},
+	79: {'1', '1'}, 80: {'2', '2'}, 81: {'3', '3'}, 82: {'0', '0'},
+	83: {'.', '.'}, 55: {'*', '*'},
+}
+
+func init() {
+	rows := []struct {
+		first uint16
+		keys  string
+	}{
+		{16, "qwertyuiop"},
+		{30, "asdfghjkl"},
+		{44, "zxcvbnm"},
+	}
+	for _, row := range rows {
+		for i, ch := range row.keys {
+			keymap[row.first+uint16(i)] = [2]rune{ch, []rune(strings.ToUpper(string(ch)))[0]}
+		}
+	}
+}
+
+// readEvdev grabs an evdev keyboard device, so its keystrokes no longer reach
+// the terminal, and sends each line it types to scans.
+func readEvdev(d Device, scans chan<- Scan) error {
+	f, err := os.Open(d.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), eviocgrab, 1); errno != 0 {
+		return errno
+	}
+
+	var line []rune
+	shift := false
+	for {
+		var ev inputEvent
+		if err := binary.Read(f, binary.LittleEndian, &ev); err != nil {
+			return err
+		}
+		if ev.Type != evKey || ev.Value == keyRepeat {
+			continue
+		}
+
+		switch ev.Code {
+		case keyLShift, keyRShift:
+			shift = ev.Value == keyPress
+		case keyEnter, keyKPEnter:
+			if ev.Value == keyPress && len(line) > 0 {
+				scans <- Scan{ID: d.ID, Barcode: string(line)}
+				line = line[:0]
+			}
+		default:
+			if ev.Value == keyRelease {
+				continue
+			}
+			if chars, ok := keymap[ev.Code]; ok {
+				if shift {
+					line = append(line, chars[1])
+				} else {
+					line = append(line, chars[0])
+				}
+			}
+		}
+	}
+}
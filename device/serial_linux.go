@@ -0,0 +1,79 @@
+//go:build linux
+// +build linux
+
+package device
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// cbaud is the termios speed mask, which the syscall package doesn't define.
+const cbaud = 0x100f
+
+// bauds maps supported serial speeds to their termios constants.
+var bauds = map[int]uint32{
+	1200:   syscall.B1200,
+	2400:   syscall.B2400,
+	4800:   syscall.B4800,
+	9600:   syscall.B9600,
+	19200:  syscall.B19200,
+	38400:  syscall.B38400,
+	57600:  syscall.B57600,
+	115200: syscall.B115200,
+}
+
+// readSerial configures a serial port for raw input at the device's baud
+// rate and sends each line read from it to scans.
+func readSerial(d Device, scans chan<- Scan) error {
+	f, err := os.OpenFile(d.Path, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := makeRaw(f, d.Baud); err != nil {
+		return err
+	}
+	return readLines(d, f, scans)
+}
+
+// makeRaw disables echo and line processing on a serial port and sets its
+// speed. A baud of 0 keeps the port's current speed.
+func makeRaw(f *os.File, baud int) error {
+	var t syscall.Termios
+	if err := ioctl(f, syscall.TCGETS, &t); err != nil {
+		return err
+	}
+
+	t.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP | syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	t.Oflag &^= syscall.OPOST
+	t.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	t.Cflag &^= syscall.CSIZE | syscall.PARENB
+	t.Cflag |= syscall.CS8 | syscall.CREAD | syscall.CLOCAL
+	t.Cc[syscall.VMIN] = 1
+	t.Cc[syscall.VTIME] = 0
+
+	if baud != 0 {
+		speed, ok := bauds[baud]
+		if !ok {
+			return fmt.Errorf("unsupported baud rate %d", baud)
+		}
+		t.Cflag &^= cbaud
+		t.Cflag |= speed
+		t.Ispeed = speed
+		t.Ospeed = speed
+	}
+
+	return ioctl(f, syscall.TCSETS, &t)
+}
+
+// ioctl performs a terminal ioctl on f.
+func ioctl(f *os.File, request uintptr, t *syscall.Termios) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), request, uintptr(unsafe.Pointer(t))); errno != 0 {
+		return errno
+	}
+	return nil
+}
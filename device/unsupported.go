@@ -0,0 +1,16 @@
+//go:build !linux
+// +build !linux
+
+package device
+
+import "errors"
+
+var errUnsupported = errors.New("direct device input is only supported on Linux")
+
+func readEvdev(d Device, scans chan<- Scan) error {
+	return errUnsupported
+}
+
+func readSerial(d Device, scans chan<- Scan) error {
+	return errUnsupported
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bhutch29/abv/model"
+	"github.com/sirupsen/logrus"
+)
+
+// EventKind is an Enum of the requests a ModalController can handle
+type EventKind int
+
+// The kinds of events handled by the ModalController.
+const (
+	scanEvent EventKind = iota
+	modeEvent
+	quantityEvent
+	undoEvent
+	redoEvent
+	newDrinkEvent
+	startBatchEvent
+	endBatchEvent
+	logoEvent
+	voidEvent
+	openTabEvent
+	closeTabEvent
+	startCountEvent
+	endCountEvent
+	statusEvent
+)
+
+// Event is a request for the ModalController to act, such as a scanned
+// barcode or a change of mode, on behalf of the input device with the given ID
+type Event struct {
+	Kind     EventKind
+	ID       string
+	Barcode  string
+	Mode     Mode
+	Quantity int
+	Drink    model.Drink
+	Logo     []byte // a custom logo image, or nil to remove the custom logo
+	ForBrand bool   // Logo is for the brand of the drink with Barcode
+	reply    chan Result
+	allows   func(scope string) bool // nil for trusted input sources
+}
+
+// Result describes the outcome of an Event. Results are published to every
+// subscriber of the ModalController, whichever input source sent the Event.
+type Result struct {
+	Event    Event
+	Messages []Message
+	Err      error `json:"-"`
+	Error    string
+	Changed  bool // the inventory was changed
+	Unknown  bool // an unrecognized barcode was scanned in stocking mode
+	Status   Status
+
+	forbidden bool // the event needed a scope its sender does not have
+}
+
+// Message is a user-facing log message produced while handling an Event
+type Message struct {
+	Level logrus.Level
+	Text  string
+}
+
+// Status is a snapshot of the ModalController's modes and quantities
+type Status struct {
+	Mode     Mode
+	Quantity int
+	Scanners []Scanner
+	Undo     []UndoStatus
+}
+
+// UndoStatus describes what the next undo and redo would do for the input
+// device with the given ID. Undo and Redo are empty if there is nothing to
+// undo or redo.
+type UndoStatus struct {
+	ID       string
+	Undo     string
+	UndoTime time.Time
+	Redo     string
+}
+
+// log records a message in the result and writes it to the log file.
+func (r *Result) log(level logrus.Level, args ...interface{}) {
+	text := fmt.Sprint(args...)
+	logFile.WithField("scanner", r.Event.ID).Log(level, text)
+	r.Messages = append(r.Messages, Message{level, text})
+}
+
+// fail records an error message in the result as its error.
+func (r *Result) fail(args ...interface{}) {
+	r.log(logrus.ErrorLevel, args...)
+	r.Error = r.Messages[len(r.Messages)-1].Text
+	r.Err = errors.New(r.Error)
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bhutch29/abv/cache"
+	"github.com/bhutch29/abv/model"
+	"github.com/spf13/viper"
+)
+
+// layouts are the menu templates a display can use
+var layouts = []string{"grid", "list", "by-style"}
+
+// errUnknownDisplay is returned for names which are not in the [displays] table
+var errUnknownDisplay = errors.New("no such display")
+
+// defaultSort is the order of drinks on displays which do not set one
+var defaultSort = []string{"shorttype", "brand", "name"}
+
+// display is how a menu is laid out, which drinks it shows and how often its
+// pages change
+type display struct {
+	Name      string
+	Layout    string
+	PageSize  int
+	Rotate    time.Duration
+	LowStock  int // drinks with fewer than LowStock in stock are highlighted
+	Thumbnail int // size of the logo thumbnails
+	Query     model.InventoryQuery
+	Theme     theme
+}
+
+// loadDisplay returns the display profile with the given name from the
+// [displays] table, or the default display of the [menu] table if the name
+// is empty. Profiles use the [menu] settings they do not set themselves.
+func loadDisplay(name string) (display, error) {
+	d := display{
+		Name:      name,
+		Layout:    conf.GetString("menu.layout"),
+		PageSize:  conf.GetInt("menu.pageSize"),
+		Rotate:    conf.GetDuration("menu.rotate"),
+		LowStock:  conf.GetInt("menu.lowStock"),
+		Thumbnail: conf.GetInt("menu.thumbnailSize"),
+		Query:     model.InventoryQuery{Sort: defaultSort},
+	}
+	if name == "" {
+		return d, d.validate()
+	}
+
+	// Dots would make conf.Sub find tables within display profiles
+	var p *viper.Viper
+	if !strings.Contains(name, ".") {
+		p = conf.Sub("displays." + name)
+	}
+	if p == nil {
+		return d, fmt.Errorf("%w: %q", errUnknownDisplay, name)
+	}
+	if p.IsSet("layout") {
+		d.Layout = p.GetString("layout")
+	}
+	if p.IsSet("pageSize") {
+		d.PageSize = p.GetInt("pageSize")
+	}
+	if p.IsSet("rotate") {
+		d.Rotate = p.GetDuration("rotate")
+	}
+	if p.IsSet("lowStock") {
+		d.LowStock = p.GetInt("lowStock")
+	}
+	if p.IsSet("thumbnailSize") {
+		d.Thumbnail = p.GetInt("thumbnailSize")
+	}
+
+	filter := url.Values{}
+	for k, v := range p.GetStringMapString("filter") {
+		filter.Set(k, v)
+	}
+	q, err := model.ParseInventoryQuery(filter)
+	if err != nil {
+		return d, fmt.Errorf("display %q: filter: %v", name, err)
+	}
+	if len(q.Sort) == 0 {
+		q.Sort = defaultSort
+	}
+	d.Query = q
+
+	if d.Theme, err = newTheme(p.GetStringMapString("theme")); err != nil {
+		return d, fmt.Errorf("display %q: %v", name, err)
+	}
+	if err = d.validate(); err != nil {
+		return d, fmt.Errorf("display %q: %v", name, err)
+	}
+	return d, nil
+}
+
+// displayNames returns the names of the display profiles in the [displays]
+// table
+func displayNames() []string {
+	var names []string
+	for name := range conf.GetStringMap("displays") {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// apply overrides the display with the query parameters layout, size,
+// rotate and low
+func (d *display) apply(q url.Values) error {
+	if s := q.Get("layout"); s != "" {
+		d.Layout = s
+	}
+	if s := q.Get("size"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return fmt.Errorf("invalid page size %q", s)
+		}
+		d.PageSize = n
+	}
+	if s := q.Get("rotate"); s != "" {
+		r, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("invalid rotate duration %q", s)
+		}
+		d.Rotate = r
+	}
+	if s := q.Get("low"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return fmt.Errorf("invalid low stock threshold %q", s)
+		}
+		d.LowStock = n
+	}
+	return d.validate()
+}
+
+func (d display) validate() error {
+	valid := false
+	for _, l := range layouts {
+		valid = valid || d.Layout == l
+	}
+	if !valid {
+		return fmt.Errorf("unknown layout %q, use one of %v", d.Layout, layouts)
+	}
+	if d.PageSize < 1 {
+		return fmt.Errorf("page size must be at least 1, not %d", d.PageSize)
+	}
+	if d.Rotate < time.Second {
+		return fmt.Errorf("pages must rotate every second or slower, not every %v", d.Rotate)
+	}
+	for _, size := range cache.Sizes() {
+		if size == d.Thumbnail {
+			return nil
+		}
+	}
+	return fmt.Errorf("thumbnail size %d is not one of thumbnailSizes %v", d.Thumbnail, cache.Sizes())
+}
+
+// inventoryQuery returns the query of the drinks on the display. Drinks on
+// by-style displays are sorted by style first, so each style is one section.
+func (d display) inventoryQuery() model.InventoryQuery {
+	q := d.Query
+	if d.Layout == "by-style" && (len(q.Sort) == 0 || q.Sort[0] != "shorttype") {
+		q.Sort = append([]string{"shorttype"}, q.Sort...)
+	}
+	return q
+}
+
+// theme is the CSS custom properties of a display's colors and fonts
+type theme map[string]string
+
+// themeProperties maps the keys of a theme table, which are lower case, to the
+// CSS custom properties used by menu.css
+var themeProperties = map[string]string{
+	"background":  "--background",
+	"tile":        "--tile",
+	"tilelow":     "--tile-low",
+	"text":        "--text",
+	"accent":      "--accent",
+	"muted":       "--muted",
+	"font":        "--font",
+	"headingfont": "--heading-font",
+}
+
+var (
+	colorValue = regexp.MustCompile(`^(#[0-9a-fA-F]{3,8}|[a-zA-Z]+|rgba?\([0-9., %]+\))$`)
+	fontValue  = regexp.MustCompile(`^[a-zA-Z0-9 -]+$`)
+)
+
+// newTheme checks the colors and font names of a theme table
+func newTheme(values map[string]string) (theme, error) {
+	t := make(theme)
+	for key, value := range values {
+		prop, ok := themeProperties[key]
+		if !ok {
+			return nil, fmt.Errorf("unknown theme setting %q", key)
+		}
+		if strings.HasSuffix(prop, "font") {
+			if !fontValue.MatchString(value) {
+				return nil, fmt.Errorf("invalid font name %q", value)
+			}
+			value = "'" + value + "'"
+		} else if !colorValue.MatchString(value) {
+			return nil, fmt.Errorf("invalid %s color %q", key, value)
+		}
+		t[prop] = value
+	}
+	return t, nil
+}
+
+// CSS returns the theme as CSS declarations for a :root rule
+func (t theme) CSS() template.CSS {
+	var props []string
+	for prop := range t {
+		props = append(props, prop)
+	}
+	sort.Strings(props)
+	var b strings.Builder
+	for _, prop := range props {
+		b.WriteString(prop + ": " + t[prop] + "; ")
+	}
+	// Values were checked by newTheme, so they cannot escape the rule
+	return template.CSS(b.String())
+}
@@ -5,13 +5,17 @@ import (
 	"flag"
 	"fmt"
 	"html/template"
+	"image"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
 	"path"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/bhutch29/abv/cache"
 	"github.com/bhutch29/abv/config"
 	"github.com/bhutch29/abv/model"
 	"github.com/julienschmidt/httprouter"
@@ -21,7 +25,9 @@ import (
 var (
 	conf     *viper.Viper
 	myClient = &http.Client{Timeout: 10 * time.Second}
+	backend  model.Backend
 	tmpl     *template.Template
+	menuTmpl *template.Template
 	version  = "undefined"
 )
 
@@ -58,18 +64,30 @@ func main() {
 	if err != nil {
 		log.Fatal("Could not get configuration: ", err)
 	}
-	imagePath := path.Join(conf.GetString("configPath"), "images")
 	webRoot := conf.GetString("webRoot")
 	fontPath := path.Join(webRoot, "static", "fonts")
 
 	frontHTML := path.Join(webRoot, "front.html")
 	tmpl = template.Must(template.ParseFiles(frontHTML))
+	menuTmpl = template.Must(template.ParseFiles(path.Join(webRoot, "menu.html")))
+	api, err := apiURL()
+	if err != nil {
+		log.Fatal("Invalid apiUrl: ", err)
+	}
+	backend = model.NewClient(api.String(), conf.GetString("apiToken"))
 
 	router := httprouter.New()
 
+	proxy := apiProxy(api)
+	for _, method := range []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"} {
+		router.Handler(method, "/api/*path", proxy)
+	}
+
 	router.GET("/", frontPageHandler)
+	router.GET("/menu", menuHandler)
+	router.GET("/displays/:name", menuHandler)
 
-	router.ServeFiles("/images/*filepath", http.Dir(imagePath))
+	router.GET("/images/*filepath", imageHandler)
 	router.ServeFiles("/static/fonts/*filepath", http.Dir(fontPath))
 
 	router.GET("/static/css/*filePath", cssHandler)
@@ -109,9 +127,45 @@ func fileHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params, h
 	http.ServeFile(w, r, fullPath)
 }
 
+// imageHandler serves cached logos at /images/<file> and their thumbnails at
+// /images/<size>/<file>, where file is the Image of a drink from the API.
+// The thumbnail /images/<size>/placeholder.jpg is for drinks without a logo.
+// Logos which cannot be made into thumbnails are served at full size.
+func imageHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	parts := strings.Split(strings.TrimPrefix(ps.ByName("filepath"), "/"), "/")
+	file := parts[len(parts)-1]
+	if file == "" || strings.HasPrefix(file, ".") || len(parts) > 2 {
+		http.NotFound(w, r)
+		return
+	}
+	original := path.Join(cache.Dir(), file)
+
+	if len(parts) == 2 {
+		size, err := strconv.Atoi(parts[0])
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		thumb, err := cache.Thumbnail(file, size)
+		if err == nil {
+			http.ServeFile(w, r, thumb)
+			return
+		}
+		if err != image.ErrFormat {
+			http.NotFound(w, r)
+			return
+		}
+	}
+
+	if info, err := os.Stat(original); err != nil || !info.Mode().IsRegular() {
+		http.NotFound(w, r)
+		return
+	}
+	http.ServeFile(w, r, original)
+}
+
 func frontPageHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-	apiURL := conf.GetString("apiUrl")
-	tmpl.Execute(w, apiURL)
+	tmpl.Execute(w, nil)
 }
 
 // TODO: this function is unused, is it needed for the frontend?
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/bhutch29/abv/cache"
+	"github.com/bhutch29/abv/model"
+	"github.com/julienschmidt/httprouter"
+)
+
+// menuDrink is a drink on a menu page
+type menuDrink struct {
+	model.StockedDrink
+	Image string // path of the logo thumbnail
+	Low   bool   // fewer than the display's LowStock are in stock
+}
+
+// section is the drinks of a single style on a by-style menu page
+type section struct {
+	Style  string
+	Drinks []menuDrink
+}
+
+// menuPage is what the menu templates are executed with
+type menuPage struct {
+	Display  display
+	Drinks   []menuDrink
+	Sections []section
+	Page     int
+	Pages    int
+	Quantity int
+	Variety  int
+	Next     string // URL of the page shown after Rotate
+}
+
+// Rows returns the number of rows of a two column grid of the display's page size
+func (p menuPage) Rows() int {
+	return (p.Display.PageSize + 1) / 2
+}
+
+// RefreshSeconds returns the Rotate of the display in whole seconds
+func (p menuPage) RefreshSeconds() int {
+	return int(p.Display.Rotate / time.Second)
+}
+
+// newMenuPage returns the given page, counting from 1, of the stocked drinks.
+// Drinks are expected to be sorted by style for the by-style layout.
+// A page past the last shows the first page.
+func newMenuPage(d display, drinks []model.StockedDrink, page int, q url.Values) menuPage {
+	p := menuPage{Display: d, Variety: len(drinks)}
+	p.Pages = (len(drinks) + d.PageSize - 1) / d.PageSize
+	if p.Pages == 0 {
+		p.Pages = 1
+	}
+	if page < 1 || page > p.Pages {
+		page = 1
+	}
+	p.Page = page
+
+	for _, drink := range drinks {
+		p.Quantity += drink.Quantity
+	}
+	start := (page - 1) * d.PageSize
+	end := start + d.PageSize
+	if end > len(drinks) {
+		end = len(drinks)
+	}
+	for _, drink := range drinks[start:end] {
+		file := cache.LogoFile(drink.Barcode, drink.StoredBrand, drink.Logo)
+		if file == "" {
+			file = cache.PlaceholderFile
+		}
+		md := menuDrink{
+			StockedDrink: drink,
+			Image:        fmt.Sprintf("/images/%d/%s", d.Thumbnail, file),
+			Low:          drink.Quantity < d.LowStock,
+		}
+		p.Drinks = append(p.Drinks, md)
+		if n := len(p.Sections); n == 0 || p.Sections[n-1].Style != drink.Shorttype {
+			p.Sections = append(p.Sections, section{Style: drink.Shorttype})
+		}
+		s := &p.Sections[len(p.Sections)-1]
+		s.Drinks = append(s.Drinks, md)
+	}
+
+	next := url.Values{}
+	for k, v := range q {
+		next[k] = v
+	}
+	next.Set("page", strconv.Itoa(page%p.Pages+1))
+	p.Next = "?" + next.Encode()
+	return p
+}
+
+// menuHandler renders a page of the menu, laid out by the display configured
+// in the [menu] table, or by the display profile named in the path, and the
+// query parameters layout, size, rotate, low and page. The page refreshes
+// itself to show the next page after rotate.
+func menuHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	d, err := loadDisplay(ps.ByName("name"))
+	if errors.Is(err, errUnknownDisplay) {
+		http.Error(w, fmt.Sprintf("%v, the displays are %v", err, displayNames()), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Println("Invalid display configuration: ", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	q := r.URL.Query()
+	if err := d.apply(q); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	page, _ := strconv.Atoi(q.Get("page"))
+
+	drinks, err := backend.QueryInventory(d.inventoryQuery())
+	if err != nil {
+		log.Println("Failed to get inventory for the menu: ", err)
+		http.Error(w, "Could not get the inventory", http.StatusBadGateway)
+		return
+	}
+	renderMenu(w, d.Layout, newMenuPage(d, drinks, page, q))
+}
+
+// renderMenu executes a layout template, so that nothing is written if the
+// template fails
+func renderMenu(w http.ResponseWriter, layout string, p menuPage) {
+	var buf bytes.Buffer
+	if err := menuTmpl.ExecuteTemplate(&buf, layout, p); err != nil {
+		log.Println("Failed to render the menu: ", err)
+		http.Error(w, "Could not render the menu", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	buf.WriteTo(w)
+}
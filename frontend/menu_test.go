@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"html/template"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bhutch29/abv/model"
+	"github.com/spf13/viper"
+)
+
+func testDrinks() []model.StockedDrink {
+	var drinks []model.StockedDrink
+	for i, style := range []string{"IPA", "IPA", "IPA", "Stout", "Stout"} {
+		d := model.StockedDrink{Quantity: i + 1}
+		d.Barcode = string(rune('a' + i))
+		d.Name = "Drink " + d.Barcode
+		d.Shorttype = style
+		drinks = append(drinks, d)
+	}
+	return drinks
+}
+
+func TestDisplayApplyInvalid(t *testing.T) {
+	for _, q := range []string{"layout=carousel", "size=0", "size=many", "rotate=10", "rotate=1ms", "low=few"} {
+		d := display{Layout: "grid", PageSize: 16, Rotate: 15 * time.Second}
+		query, _ := url.ParseQuery(q)
+		if err := d.apply(query); err == nil {
+			t.Errorf("%s was accepted", q)
+		}
+	}
+}
+
+func TestNewMenuPage(t *testing.T) {
+	d := display{Layout: "by-style", PageSize: 2, Rotate: 15 * time.Second, LowStock: 2, Thumbnail: 128}
+	q := url.Values{"layout": {"by-style"}, "page": {"2"}}
+
+	p := newMenuPage(d, testDrinks(), 2, q)
+	if p.Page != 2 || p.Pages != 3 || p.Quantity != 15 || p.Variety != 5 {
+		t.Errorf("page %d of %d with %d drinks of %d varieties", p.Page, p.Pages, p.Quantity, p.Variety)
+	}
+	if len(p.Drinks) != 2 || p.Drinks[0].Barcode != "c" || p.Drinks[1].Barcode != "d" {
+		t.Errorf("unexpected drinks on page 2: %+v", p.Drinks)
+	}
+	if len(p.Sections) != 2 || p.Sections[0].Style != "IPA" || p.Sections[1].Style != "Stout" {
+		t.Errorf("unexpected sections on page 2: %+v", p.Sections)
+	}
+	if p.Next != "?layout=by-style&page=3" {
+		t.Errorf("next page is %s", p.Next)
+	}
+	if p.Drinks[0].Image != "/images/128/placeholder.jpg" {
+		t.Errorf("drink without a logo has image %s", p.Drinks[0].Image)
+	}
+
+	last := newMenuPage(d, testDrinks(), 3, q)
+	if len(last.Drinks) != 1 || !strings.HasSuffix(last.Next, "page=1") {
+		t.Errorf("last page has %d drinks and next page %s", len(last.Drinks), last.Next)
+	}
+	if first := newMenuPage(d, testDrinks(), 9, q); first.Page != 1 || !first.Drinks[0].Low {
+		t.Errorf("page past the last is page %d", first.Page)
+	}
+	if empty := newMenuPage(d, nil, 1, q); empty.Pages != 1 || len(empty.Drinks) != 0 {
+		t.Errorf("menu of no drinks has %d pages", empty.Pages)
+	}
+}
+
+func TestMenuTemplates(t *testing.T) {
+	menuTmpl = template.Must(template.ParseFiles("menu.html"))
+	for _, layout := range layouts {
+		d := display{Layout: layout, PageSize: 4, Rotate: 15 * time.Second, LowStock: 3, Thumbnail: 128}
+		rec := httptest.NewRecorder()
+		renderMenu(rec, layout, newMenuPage(d, testDrinks(), 1, url.Values{}))
+
+		body := rec.Body.String()
+		if rec.Code != 200 {
+			t.Errorf("%s: status %d: %s", layout, rec.Code, body)
+			continue
+		}
+		for _, want := range []string{"Drink a", "Drink d", "quantity-low", `content="15;url=?page=2"`} {
+			if !strings.Contains(body, want) {
+				t.Errorf("%s: %q is missing from the page", layout, want)
+			}
+		}
+		if strings.Contains(body, "Drink e") {
+			t.Errorf("%s: drink from the second page is shown", layout)
+		}
+	}
+}
+
+func TestLoadDisplayErrors(t *testing.T) {
+	old := conf
+	defer func() { conf = old }()
+	conf = viper.New()
+	conf.Set("displays.bad-filter.filter", map[string]interface{}{"min_quantity": "lots"})
+	conf.Set("displays.bad-theme.theme", map[string]interface{}{"accent": "red; } body { display: none"})
+
+	conf.Set("displays.taps.filter", map[string]interface{}{"style": "ipa"})
+	for _, name := range []string{"lounge", "taps.filter", "bad-theme.theme"} {
+		if _, err := loadDisplay(name); !errors.Is(err, errUnknownDisplay) {
+			t.Errorf("unknown display %s returned %v", name, err)
+		}
+	}
+	for _, name := range []string{"bad-filter", "bad-theme"} {
+		if _, err := loadDisplay(name); err == nil || errors.Is(err, errUnknownDisplay) {
+			t.Errorf("display %s returned %v", name, err)
+		}
+	}
+}
+
+func TestTheme(t *testing.T) {
+	th, err := newTheme(map[string]string{"accent": "#ffa623", "tilelow": "rgb(78, 8, 20)", "headingfont": "Bowlby One SC"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "--accent: #ffa623; --heading-font: 'Bowlby One SC'; --tile-low: rgb(78, 8, 20); "
+	if css := string(th.CSS()); css != want {
+		t.Errorf("theme CSS is %q, want %q", css, want)
+	}
+	for _, bad := range []map[string]string{
+		{"border": "red"},
+		{"text": "url(http://example.com)"},
+		{"font": "Oswald'; color: red"},
+	} {
+		if _, err := newTheme(bad); err == nil {
+			t.Errorf("theme %v was accepted", bad)
+		}
+	}
+}
+
+func TestByStyleSort(t *testing.T) {
+	d := display{Layout: "by-style", Query: model.InventoryQuery{Sort: []string{"abv"}}}
+	if s := d.inventoryQuery().Sort; strings.Join(s, ",") != "shorttype,abv" {
+		t.Errorf("by-style display is sorted by %v", s)
+	}
+	d.Layout = "grid"
+	if s := d.inventoryQuery().Sort; strings.Join(s, ",") != "abv" {
+		t.Errorf("grid display is sorted by %v", s)
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+)
+
+// apiURL returns the URL of the API from the apiUrl setting. A bare host
+// such as "192.168.0.100" is the API on its default port, 8081.
+func apiURL() (*url.URL, error) {
+	s := conf.GetString("apiUrl")
+	if !strings.Contains(s, "://") {
+		s = "http://" + s + ":8081"
+	}
+	return url.Parse(strings.TrimSuffix(s, "/"))
+}
+
+// apiProxy forwards requests for /api/<path> to <path> of the API, so that
+// menu pages reach the API on their own origin, whatever its address.
+// Failures to reach the API are reported like API errors.
+func apiProxy(target *url.URL) http.Handler {
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		log.Println("Failed to reach the API at ", target, ": ", err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": map[string]string{"code": "bad_gateway", "message": "could not reach the API"},
+		})
+	}
+	return http.StripPrefix("/api", proxy)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestAPIURL(t *testing.T) {
+	old := conf
+	defer func() { conf = old }()
+	conf = viper.New()
+	for in, want := range map[string]string{
+		"localhost":                 "http://localhost:8081",
+		"https://abv.example.com/":  "https://abv.example.com",
+		"http://192.168.0.100:9000": "http://192.168.0.100:9000",
+	} {
+		conf.Set("apiUrl", in)
+		if u, err := apiURL(); err != nil || u.String() != want {
+			t.Errorf("apiUrl %s is %v (%v), want %s", in, u, err, want)
+		}
+	}
+}
+
+func TestAPIProxy(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Method + " " + r.URL.RequestURI()))
+	}))
+	target, _ := url.Parse(api.URL)
+
+	rec := httptest.NewRecorder()
+	apiProxy(target).ServeHTTP(rec, httptest.NewRequest("GET", "/api/inventory?sort=abv", nil))
+	if body := rec.Body.String(); body != "GET /inventory?sort=abv" {
+		t.Errorf("API received %q", body)
+	}
+
+	api.Close()
+	rec = httptest.NewRecorder()
+	apiProxy(target).ServeHTTP(rec, httptest.NewRequest("GET", "/api/inventory", nil))
+	if rec.Code != http.StatusBadGateway || !strings.Contains(rec.Body.String(), "bad_gateway") {
+		t.Errorf("unreachable API returned %d: %s", rec.Code, rec.Body.String())
+	}
+}
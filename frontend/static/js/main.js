@@ -3,18 +3,19 @@ persist.index = 0;
 
 let defaultTimer = 15000;
 let beersPerPage = 16; // must also change CSS grid number
+let thumbnailSize = 128; // must be one of thumbnailSizes in config.toml
 
 
 function changePage(){
-    $.getJSON("http://" + window.apiUrl + ":8081/inventory/quantity", function(quantity){
+    $.getJSON("api/inventory/quantity", function(quantity){
         $('#quantity-view').html(quantity + " beers left");
     });
 
-    $.getJSON("http://" + window.apiUrl + ":8081/inventory/variety", function(variety){
+    $.getJSON("api/inventory/variety", function(variety){
         $('#variety-view').html(variety + " varieties to choose from");
     });
 
-    $.getJSON("http://" + window.apiUrl + ":8081/inventory", function(beers){
+    $.getJSON("api/inventory", function(beers){
         $("#beer-list").empty();
         if (persist.index >= beers.length) {
             persist.index = 0;
@@ -36,9 +37,8 @@ function changePage(){
 };
 
 function setImagePath(beer) {
-    var url = beer.Logo;
-    var file = url.substring(url.lastIndexOf('/') + 1);
-    beer.Logo = "/images/" + file;
+    var file = beer.Image ? beer.Image : "placeholder.jpg";
+    beer.Logo = "/images/" + thumbnailSize + "/" + file;
 }
 
 function createBeerEntry(beer) {
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+// runHeadless drives the controller from scanner devices and stdin without a
+// terminal user interface, until the process is interrupted.
+//
+// Everything is logged to abv.log. The controller is served on the configured
+// stationAddress, so status can be checked and a user interface can attach
+// with -attach to add new drinks.
+func runHeadless(ctrl *ModalController) {
+	logGui.Out = ioutil.Discard
+
+	go logUnknownBarcodes(ctrl.Subscribe())
+
+	if err := listenDevices(ctrl, func(err error) { logFile.Error(err) }); err != nil {
+		logFile.Fatal("Error reading scanner devices: ", err)
+	}
+	go readStdin(ctrl)
+
+	addr := conf.GetString("stationAddress")
+	go func() {
+		logFile.Fatal("Error serving station: ", serveStation(ctrl, addr))
+	}()
+	logFile.Info("Running headless, serving station on ", addr)
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	logFile.Info("Exiting on ", <-sig)
+}
+
+// readStdin sends every line read from stdin to the controller as a scan.
+func readStdin(ctrl *ModalController) {
+	s := bufio.NewScanner(os.Stdin)
+	for s.Scan() {
+		bc := strings.TrimSpace(s.Text())
+		if bc == "" {
+			continue
+		}
+		id, barcode := parseIDFromBarcode(bc)
+		ctrl.Post(Event{Kind: scanEvent, ID: id, Barcode: barcode})
+	}
+	logFile.Info("Stopped reading barcodes from stdin")
+}
+
+// logUnknownBarcodes logs new barcodes scanned in stocking mode, which need
+// an attached user interface to be added as drinks.
+func logUnknownBarcodes(results <-chan Result) {
+	for r := range results {
+		if r.Unknown {
+			logFile.WithField("scanner", r.Event.ID).Warn("Barcode not recognized: ", r.Event.Barcode, ". Attach a user interface to add the new drink")
+		}
+	}
+}
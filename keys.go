@@ -2,18 +2,22 @@ package main
 
 import (
 	"fmt"
-
-	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/jroimartin/gocui"
 	aur "github.com/logrusorgru/aurora"
 )
 
 // key is a representation of a keybinding.
+//
+// Keybindings with a non-empty action can be remapped by the user in the
+// [keybindings] table of the config file.
 type key struct {
 	viewname  string
+	action    string
 	key       interface{}
+	mod       gocui.Modifier
 	handler   func(*gocui.Gui, *gocui.View) error
 	shortkey  string
 	shortname string
@@ -21,39 +25,192 @@ type key struct {
 
 var keys []key
 
-// initializekeys sets up all keybindings for the main gui.
-func initializekeys() {
+// quantityActions maps the quantity keybinding actions to the number of
+// drinks per scan they select.
+var quantityActions = map[string]int{
+	"single":      1,
+	"four-pack":   4,
+	"six-pack":    6,
+	"twelve-pack": 12,
+}
+
+// initializekeys sets up all keybindings for the main gui, applying any
+// remapped keys from the config file.
+func initializekeys() error {
 	keys = []key{
-		{"", gocui.KeyCtrlI, setInputMode, "Ctrl-i", "stocking"},
-		{"", gocui.KeyCtrlO, setOutputMode, "Ctrl-o", "serving"},
-		{"", gocui.KeyCtrlZ, undoLastKeyboardAction, "Ctrl-z", "undo"},
-		{"", gocui.KeyCtrlR, redoLastKeyboardAction, "Ctrl-r", "redo"},
-		{"", gocui.KeyCtrlC, quit, "Ctrl-c", "quit"},
-		{"", gocui.KeyF1, setQuantity1, "F1", "single"},
-		{"", gocui.KeyF4, setQuantity4, "F4", "four-pack"},
-		{"", gocui.KeyF6, setQuantity6, "F6", "six-pack"},
-		{"", gocui.KeyF12, setQuantity12, "F12", "twelve-pack"},
-		{input, gocui.KeyArrowUp, scrollInventoryUp, "Up", "scroll up"},
-		{input, gocui.KeyArrowDown, scrollInventoryDown, "Down", "scroll down"},
-		{input, gocui.KeyEnter, parseInput, "Enter", "confirm"},
-		{search, gocui.KeyEnter, handleSearch, "Enter", "confirm"},
-		{search, gocui.KeyEsc, cancelSearch, "Ctrl-z", "cancel"},
-		{popup, gocui.KeyEsc, cancelPopup, "Ctrl-z", "cancel"},
-		{popup, gocui.KeyArrowUp, popupScrollUp, "Up", "scrollUp"},
-		{popup, gocui.KeyCtrlK, popupScrollUp, "Up", "scrollUp"},
-		{popup, gocui.KeyArrowDown, popupScrollDown, "Down", "scrollDown"},
-		{popup, gocui.KeyCtrlJ, popupScrollDown, "Down", "scrollDown"},
-		{popup, gocui.KeyEnter, popupSelectItem, "Enter", "Select"},
-		{errorView, gocui.KeyEsc, hideError, "Esc", "close error dialog"},
+		{"", "stocking", gocui.KeyCtrlI, gocui.ModNone, setInputMode, "Ctrl-i", "stocking"},
+		{"", "serving", gocui.KeyCtrlO, gocui.ModNone, setOutputMode, "Ctrl-o", "serving"},
+		{"", "undo", gocui.KeyCtrlZ, gocui.ModNone, undoLastKeyboardAction, "Ctrl-z", "undo"},
+		{"", "redo", gocui.KeyCtrlR, gocui.ModNone, redoLastKeyboardAction, "Ctrl-r", "redo"},
+		{"", "quit", gocui.KeyCtrlC, gocui.ModNone, quit, "Ctrl-c", "quit"},
+		{"", "single", gocui.KeyF1, gocui.ModNone, setQuantity1, "F1", "single"},
+		{"", "four-pack", gocui.KeyF4, gocui.ModNone, setQuantity4, "F4", "four-pack"},
+		{"", "six-pack", gocui.KeyF6, gocui.ModNone, setQuantity6, "F6", "six-pack"},
+		{"", "twelve-pack", gocui.KeyF12, gocui.ModNone, setQuantity12, "F12", "twelve-pack"},
+		{input, "scroll-up", gocui.KeyArrowUp, gocui.ModNone, scrollInventoryUp, "Up", "scroll up"},
+		{input, "scroll-down", gocui.KeyArrowDown, gocui.ModNone, scrollInventoryDown, "Down", "scroll down"},
+		{input, "", gocui.KeyEnter, gocui.ModNone, parseInput, "Enter", "confirm"},
+		{search, "", gocui.KeyEnter, gocui.ModNone, handleSearch, "Enter", "confirm"},
+		{search, "", gocui.KeyEsc, gocui.ModNone, cancelSearch, "Ctrl-z", "cancel"},
+		{popup, "", gocui.KeyEsc, gocui.ModNone, cancelPopup, "Ctrl-z", "cancel"},
+		{popup, "", gocui.KeyArrowUp, gocui.ModNone, popupScrollUp, "Up", "scrollUp"},
+		{popup, "", gocui.KeyCtrlK, gocui.ModNone, popupScrollUp, "Up", "scrollUp"},
+		{popup, "", gocui.KeyArrowDown, gocui.ModNone, popupScrollDown, "Down", "scrollDown"},
+		{popup, "", gocui.KeyCtrlJ, gocui.ModNone, popupScrollDown, "Down", "scrollDown"},
+		{popup, "", gocui.KeyEnter, gocui.ModNone, popupSelectItem, "Enter", "Select"},
+		{errorView, "", gocui.KeyEsc, gocui.ModNone, hideError, "Esc", "close error dialog"},
+	}
+
+	if err := remapKeys(conf.GetStringMapString("keybindings")); err != nil {
+		return err
+	}
+	return validateKeys()
+}
+
+// remapKeys replaces the default key of each action named in bindings.
+func remapKeys(bindings map[string]string) error {
+	for action, name := range bindings {
+		k := findKey(action)
+		if k == nil {
+			return fmt.Errorf("unknown keybinding action %q", action)
+		}
+		parsed, mod, shortkey, err := parseKeyName(name)
+		if err != nil {
+			return fmt.Errorf("keybinding for %q: %v", action, err)
+		}
+		k.key = parsed
+		k.mod = mod
+		k.shortkey = shortkey
+	}
+	return nil
+}
+
+// findKey returns the keybinding with the given remappable action, or nil
+// if no such action exists.
+func findKey(action string) *key {
+	for i := range keys {
+		if keys[i].action != "" && keys[i].action == action {
+			return &keys[i]
+		}
+	}
+	return nil
+}
+
+// editingKeys are the keys promptEditor uses to edit the input line.
+var editingKeys = map[gocui.Key]bool{
+	gocui.KeySpace:      true,
+	gocui.KeyBackspace:  true,
+	gocui.KeyBackspace2: true,
+	gocui.KeyDelete:     true,
+	gocui.KeyInsert:     true,
+	gocui.KeyArrowLeft:  true,
+	gocui.KeyArrowRight: true,
+}
+
+// validateKeys ensures no two keybindings are triggered by the same key
+// press, and that no keybinding in an editable view takes a key needed for
+// typing. Global keybindings conflict with keybindings in every view.
+func validateKeys() error {
+	for i, a := range keys {
+		if blocksTyping(a) {
+			return fmt.Errorf("keybinding %s for %q would prevent typing in the input line", a.shortkey, a.shortname)
+		}
+		for _, b := range keys[i+1:] {
+			if a.key != b.key || a.mod != b.mod {
+				continue
+			}
+			if a.viewname == b.viewname || a.viewname == "" || b.viewname == "" {
+				return fmt.Errorf("keybinding %s for %q conflicts with %q", a.shortkey, a.shortname, b.shortname)
+			}
+		}
+	}
+	return nil
+}
+
+// blocksTyping reports whether k is bound to an unmodified character or
+// editing key in a view where it would otherwise be typed.
+func blocksTyping(k key) bool {
+	if k.mod != gocui.ModNone || (k.viewname != "" && k.viewname != input && k.viewname != search) {
+		return false
+	}
+	switch v := k.key.(type) {
+	case rune:
+		return true
+	case gocui.Key:
+		return editingKeys[v]
+	}
+	return false
+}
+
+// namedKey is a special key which can be referred to by name in the config file.
+type namedKey struct {
+	key  gocui.Key
+	name string
+}
+
+// namedKeys maps the lowercased name of every supported special key to the
+// gocui key and the name displayed in the keybinding hints.
+var namedKeys = map[string]namedKey{
+	"up":         {gocui.KeyArrowUp, "Up"},
+	"down":       {gocui.KeyArrowDown, "Down"},
+	"left":       {gocui.KeyArrowLeft, "Left"},
+	"right":      {gocui.KeyArrowRight, "Right"},
+	"enter":      {gocui.KeyEnter, "Enter"},
+	"esc":        {gocui.KeyEsc, "Esc"},
+	"tab":        {gocui.KeyTab, "Tab"},
+	"space":      {gocui.KeySpace, "Space"},
+	"backspace":  {gocui.KeyBackspace2, "Backspace"},
+	"delete":     {gocui.KeyDelete, "Delete"},
+	"insert":     {gocui.KeyInsert, "Insert"},
+	"home":       {gocui.KeyHome, "Home"},
+	"end":        {gocui.KeyEnd, "End"},
+	"pgup":       {gocui.KeyPgup, "PgUp"},
+	"pgdn":       {gocui.KeyPgdn, "PgDn"},
+	"ctrl-space": {gocui.KeyCtrlSpace, "Ctrl-Space"},
+}
+
+func init() {
+	fkeys := []gocui.Key{
+		gocui.KeyF1, gocui.KeyF2, gocui.KeyF3, gocui.KeyF4, gocui.KeyF5, gocui.KeyF6,
+		gocui.KeyF7, gocui.KeyF8, gocui.KeyF9, gocui.KeyF10, gocui.KeyF11, gocui.KeyF12,
+	}
+	for i, k := range fkeys {
+		name := fmt.Sprintf("F%d", i+1)
+		namedKeys[strings.ToLower(name)] = namedKey{k, name}
+	}
+	for ch := 'a'; ch <= 'z'; ch++ {
+		name := "Ctrl-" + string(ch)
+		namedKeys[strings.ToLower(name)] = namedKey{gocui.KeyCtrlA + gocui.Key(ch-'a'), name}
 	}
 }
 
+// parseKeyName converts a key name from the config file, such as "F4",
+// "Ctrl-i" or "Alt-4", into a gocui key, modifier and display name.
+//
+// Plain printable characters are not accepted, since they would prevent
+// typing in the input line.
+func parseKeyName(name string) (interface{}, gocui.Modifier, string, error) {
+	trimmed := strings.TrimSpace(name)
+	lower := strings.ToLower(trimmed)
+	if k, ok := namedKeys[lower]; ok {
+		return k.key, gocui.ModNone, k.name, nil
+	}
+	if strings.HasPrefix(lower, "alt-") {
+		ch := trimmed[len("alt-"):]
+		if utf8.RuneCountInString(ch) == 1 {
+			r, _ := utf8.DecodeRuneInString(ch)
+			return r, gocui.ModAlt, "Alt-" + ch, nil
+		}
+	}
+	return nil, gocui.ModNone, "", fmt.Errorf("unrecognized key %q", name)
+}
+
 // generateKeybindString produces a hint string for each keybinding.
 func generateKeybindString(quantity int) string {
 	var result string
 	for _, k := range keys {
 		if k.viewname == "" || k.viewname == input {
-			if getKeyQuantity(k.shortkey) == quantity {
+			if q, ok := quantityActions[k.action]; ok && q == quantity {
 				result += fmt.Sprintf("%s->%s ", aur.BgBlue(aur.Black(k.shortkey)), k.shortname)
 				continue
 			}
@@ -63,21 +220,10 @@ func generateKeybindString(quantity int) string {
 	return result
 }
 
-// getKeyQuantity parses the number from a string representing a function key name.
-//
-// If the provided string is not a function key name, -1 is returned instead.
-func getKeyQuantity(shortkey string) int {
-	res, err := strconv.Atoi(strings.TrimPrefix(shortkey, "F"))
-	if err != nil {
-		return -1
-	}
-	return res
-}
-
 // configureKeys registers all keybindings with the main gocui object.
 func configureKeys() error {
 	for _, key := range keys {
-		err := g.SetKeybinding(key.viewname, key.key, gocui.ModNone, key.handler)
+		err := g.SetKeybinding(key.viewname, key.key, key.mod, key.handler)
 		if err != nil {
 			return err
 		}
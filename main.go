@@ -4,16 +4,18 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
 	"os/exec"
 	"strings"
+	"time"
 
 	"github.com/bhutch29/abv/cache"
 	"github.com/bhutch29/abv/config"
 	"github.com/bhutch29/abv/model"
 	"github.com/jroimartin/gocui"
-	aur "github.com/logrusorgru/aurora"
+	homedir "github.com/mitchellh/go-homedir"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 	"golang.org/x/crypto/ssh/terminal"
@@ -22,18 +24,15 @@ import (
 
 var (
 	g        *gocui.Gui
-	c        ModalController
-	drinks   []model.Drink
-	quantity int
+	c        Station
 	conf     *viper.Viper
+	headless bool
+	attach   string
 	version  = "undefined"
+	logos    = cache.NewPrefetcher(16, 3, 30*time.Second)
 )
 
 func init() {
-	quantity = 1
-
-	initializekeys()
-
 	//Setup loggers
 	f := logrus.TextFormatter{}
 	f.ForceColors = true
@@ -57,21 +56,58 @@ func main() {
 		log.Fatal("Error getting configuration info: ", err)
 	}
 
+	//Setup keybindings
+	if err = initializekeys(); err != nil {
+		log.Fatal("Error reading keybindings: ", err)
+	}
+
 	// Redirect stderr to log file
 	file := redirectStderr(logFile)
 	defer file.Close()
 
-	//Create Controller
-	if c, err = New(); err != nil {
-		logFile.Fatal("Error creating controller: ", err)
-	}
-
 	//Command Line flags
 	handleFlags()
 
+	//Create Controller, or connect to a headless one
+	var ctrl *ModalController
+	if attach != "" {
+		c = newRemoteStation(attach, conf.GetString("apiToken"))
+	} else {
+		if ctrl, err = New(); err != nil {
+			logFile.Fatal("Error creating controller: ", err)
+		}
+		go ctrl.Run()
+		c = ctrl
+	}
+
+	if headless {
+		runHeadless(ctrl)
+		return
+	}
+
+	//Cache logos of new drinks in the background
+	logos.OnError = func(url string, err error, willRetry bool) {
+		logFile.WithField("retrying", willRetry).Warn("Failed to cache image ", url, ": ", err)
+	}
+	go logos.Run()
+
 	//Setup GUI
 	setupGui()
 	defer g.Close()
+	go showResults(c.Subscribe())
+
+	//Read directly attached scanners
+	if ctrl != nil {
+		err := listenDevices(ctrl, func(err error) {
+			g.Update(func(*gocui.Gui) error {
+				logAllError(err)
+				return nil
+			})
+		})
+		if err != nil {
+			logFile.Fatal("Error reading scanner devices: ", err)
+		}
+	}
 
 	// Start Gui
 	if err := g.MainLoop(); err != nil && err != gocui.ErrQuit {
@@ -81,12 +117,18 @@ func main() {
 
 func handleFlags() {
 	backup := flag.String("backup", "", "Backs up the sqlite database to specified file")
-	reset := flag.Bool("reset", false, "Backs up the database to the working directory and wipes out the Input and Output tables")
+	reset := flag.Bool("reset", false, "Backs up the database to the working directory and wipes out the Input, Output and Corrections tables")
 	ver := flag.Bool("version", false, "Prints the version")
 	verbose := flag.Bool("v", false, "Increases the logging verbosity in the GUI")
+	flag.BoolVar(&headless, "headless", false, "Runs without a user interface, reading scanner devices and stdin and serving the station endpoint")
+	flag.StringVar(&attach, "attach", "", "Runs the user interface against the headless abv at the given host:port")
 
 	flag.Parse()
 
+	if headless && attach != "" {
+		log.Fatal("-headless and -attach cannot be used together")
+	}
+
 	if *ver {
 		fmt.Println(version)
 		os.Exit(0)
@@ -100,7 +142,7 @@ func handleFlags() {
 	if *reset {
 		//TODO: backup to configPath
 		backupDatabase("backup.sqlite")
-		if err := c.ClearInputOutputRecords(); err != nil {
+		if err := clearInputOutputRecords(); err != nil {
 			log.Print("Error clearing Input and Output records" + err.Error())
 			logFile.Fatal(err)
 		}
@@ -112,6 +154,21 @@ func handleFlags() {
 	}
 }
 
+// clearInputOutputRecords wipes out all stocking, serving and count correction records.
+func clearInputOutputRecords() error {
+	m, err := model.NewBackend()
+	if err != nil {
+		return err
+	}
+	if err := m.ClearInputTable(); err != nil {
+		return err
+	}
+	if err := m.ClearOutputTable(); err != nil {
+		return err
+	}
+	return m.ClearCorrectionsTable()
+}
+
 // backupDatabase backs up the abv drinks database to the given destination.
 func backupDatabase(destination string) {
 	log.Print("Backup up database to " + destination)
@@ -169,27 +226,79 @@ func refreshInventory() error {
 }
 
 // parseInput handles all input to the user interface and determines whether
-// it should be handled as a barcode or as a predefined action. (i.e. undo/redo)
+// it should be handled as a barcode or as a configured command barcode.
+// Input starting with ":" is a typed command.
 func parseInput(_ *gocui.Gui, v *gocui.View) error {
 	bc := strings.TrimSuffix(v.Buffer(), "\n")
 	clearView(input)
 	if bc == "" {
 		return nil
 	}
+	if strings.HasPrefix(bc, ":") {
+		runCommand(bc)
+		return nil
+	}
 
 	id, barcode := parseIDFromBarcode(bc)
-	undoCode := conf.GetString("undoBarcode")
-	redoCode := conf.GetString("redoBarcode")
-	if barcode == undoCode {
-		c.Undo(id)
-		refreshInventory()
-	} else if barcode == redoCode {
-		c.Redo(id)
+	c.Post(Event{Kind: scanEvent, ID: id, Barcode: barcode})
+	return nil
+}
+
+// runCommand handles a command typed into the input line:
+//
+//	:logo <barcode> <file>        sets the custom logo of a drink
+//	:brand-logo <barcode> <file>  sets the custom logo of the drink's brand
+//
+// A file of "-" removes the custom logo.
+func runCommand(line string) {
+	fields := strings.SplitN(strings.TrimPrefix(line, ":"), " ", 3)
+	if len(fields) != 3 || (fields[0] != "logo" && fields[0] != "brand-logo") {
+		logAllError("Unknown command ", line, ". Use :logo <barcode> <file> or :brand-logo <barcode> <file>")
+		return
+	}
+
+	e := Event{Kind: logoEvent, Barcode: fields[1], ForBrand: fields[0] == "brand-logo"}
+	if file := strings.TrimSpace(fields[2]); file != "-" {
+		name, err := homedir.Expand(file)
+		if err != nil {
+			logAllError("Could not read logo: ", err)
+			return
+		}
+		if e.Logo, err = ioutil.ReadFile(name); err != nil {
+			logAllError("Could not read logo: ", err)
+			return
+		}
+	}
+	c.Post(e)
+}
+
+// showResults displays the result of every event handled by the controller,
+// whichever input source it came from.
+func showResults(results <-chan Result) {
+	for r := range results {
+		r := r
+		g.Update(func(*gocui.Gui) error {
+			showResult(r)
+			return nil
+		})
+	}
+}
+
+// showResult logs the messages of a controller result and updates the views
+// it affects. Unrecognized barcodes in stocking mode open the new drink popup.
+func showResult(r Result) {
+	for _, m := range r.Messages {
+		logGui.Log(m.Level, m.Text)
+	}
+	if r.Changed {
 		refreshInventory()
-	} else {
-		handleBarcodeEntry(id, barcode)
 	}
-	return nil
+	updatePromptSymbol(r.Status.Mode)
+	updateKeybindHints(r.Status.Quantity)
+	updateUndoPanel(r.Status)
+	if r.Unknown {
+		handleNewBarcode(r.Event.ID, r.Event.Barcode)
+	}
 }
 
 // parseIDFromBarcode returns the input device ID from a line of text.
@@ -208,33 +317,15 @@ func parseIDFromBarcode(bc string) (string, string) {
 	return "", bc
 }
 
-// handleBarcodeEntry determines whether a barcode should result in the creation
-// of a new drink model, or otherwise be handled as a stocking or serving event.
-func handleBarcodeEntry(id string, bc string) {
-	logAllDebug("Scanned barcode: ", bc, " with ID=", id)
-	exists, err := c.HandleBarcode(id, bc, quantity)
-	if err != nil {
-		logAllError("Failed to search database for barcode", err)
-		return
-	}
-
-	if !exists {
-		handleNewBarcode()
-	}
-
-	refreshInventory()
-}
-
-// handleNewBarcode determines whether an unrecognized barcode should initiate
-// the creation of a new drink model.
-//
-// In serving mode, the attempt is logged and no action is taken.
-func handleNewBarcode() {
-	if c.GetMode() != stocking {
-		logGui.Warn("Barcode not recognized while serving. Drink will not be recorded")
+// handleNewBarcode initiates the creation of a new drink model for a barcode
+// which was not recognized in stocking mode.
+func handleNewBarcode(id string, bc string) {
+	if popupDisplayed {
+		logAllWarn("Barcode not recognized. Finish adding the previous new drink, then scan it again.")
 		return
 	}
 
+	pendingID, pendingBarcode = id, bc
 	logAllInfo("Barcode not recognized. Please enter drink brand and name.")
 	clearView(popup)
 	togglePopup()
@@ -275,7 +366,7 @@ func updatePopup(name string) {
 	v, _ := g.View(popup)
 
 	var err error
-	drinks, err = SearchUntappdByName(name)
+	searchResults, err = SearchUntappdByName(name)
 	if err != nil {
 		logFile.Error(err)
 		displayError(err)
@@ -283,7 +374,7 @@ func updatePopup(name string) {
 	}
 
 	v.Clear()
-	for _, drink := range drinks {
+	for _, drink := range searchResults {
 		fmt.Fprintf(v, "%s:: %s\n", drink.Brand, drink.Name)
 	}
 
@@ -293,7 +384,7 @@ func updatePopup(name string) {
 
 // popupSelectItem takes the user's selected drink, creates a new drink model
 // from the Untappd response, caches a brand image if not already cached,
-// and finally refreshes the displayed inventory.
+// and finally sends the new drink to the controller.
 func popupSelectItem(_ *gocui.Gui, v *gocui.View) error {
 	line, err := getViewLine(v)
 	if err != nil {
@@ -316,23 +407,15 @@ func popupSelectItem(_ *gocui.Gui, v *gocui.View) error {
 		return nil
 	}
 
-	err = cache.Image(d.Logo)
-	if err != nil {
-		logAllError("Failed HTTP request while caching image for drink: ", d.Brand, " ", d.Name)
-	}
+	logos.Enqueue(d.Logo)
 
-	d.Barcode = c.LastBarcode()
+	d.Barcode = pendingBarcode
 	d.Shorttype = shortenType(d.Type)
-	id := c.LastID()
+	id := pendingID
 
 	logAllDebug("Adding new drink", d)
 
-	if err = c.NewDrink(id, d, quantity); err != nil {
-		logAllError(err)
-	}
-
-	refreshInventory()
-
+	c.Post(Event{Kind: newDrinkEvent, ID: id, Drink: d})
 	return nil
 }
 
@@ -357,7 +440,7 @@ func findDrinkFromSelection(line string) (model.Drink, error) {
 
 	logFile.Debug("Determined that brand = " + brand + " and name = " + name)
 
-	for _, drink := range drinks {
+	for _, drink := range searchResults {
 		if drink.Brand == brand && drink.Name == name {
 			return drink, nil
 		}
@@ -367,40 +450,25 @@ func findDrinkFromSelection(line string) (model.Drink, error) {
 
 // setInputMode prepares the modal controller for stocking mode.
 func setInputMode(_ *gocui.Gui, _ *gocui.View) error {
-	if c.GetMode() == stocking {
-		return nil
-	}
-	c.SetMode(stocking)
-	updatePromptSymbol()
-	logGui.Infof("Changed to %s Mode", aur.Brown("Stocking"))
-	logFile.WithField("mode", stocking).Info("Changed Mode")
+	c.Post(Event{Kind: modeEvent, Mode: stocking})
 	return nil
 }
 
 // setOutputMode prepares the modal controller for serving mode.
-func setOutputMode(g *gocui.Gui, v *gocui.View) error {
-	if c.GetMode() == serving {
-		return nil
-	}
-	c.SetMode(serving)
-	setQuantity1(g, v)
-	updatePromptSymbol()
-	logGui.Infof("Changed to %s Mode", aur.Green("Serving"))
-	logFile.WithField("mode", serving).Info("Changed Mode")
+func setOutputMode(_ *gocui.Gui, _ *gocui.View) error {
+	c.Post(Event{Kind: modeEvent, Mode: serving})
 	return nil
 }
 
 // undoLastKeyboardAction reverts the previously performed action by the keyboard.
 func undoLastKeyboardAction(_ *gocui.Gui, _ *gocui.View) error {
-	c.Undo("")
-	refreshInventory()
+	c.Post(Event{Kind: undoEvent})
 	return nil
 }
 
 // redoLastKeyboardAction performs the previously reverted action by the keyboard.
 func redoLastKeyboardAction(_ *gocui.Gui, _ *gocui.View) error {
-	c.Redo("")
-	refreshInventory()
+	c.Post(Event{Kind: redoEvent})
 	return nil
 }
 
@@ -422,23 +490,18 @@ func scrollInventoryDown(g *gocui.Gui, _ *gocui.View) error {
 	return nil
 }
 
-// trySetQuantity sets the quantity-per-scan to the given quantity q.
-//
-// If the user is in serving mode, the quantity is set to 1.
+// trySetQuantity requests that the quantity-per-scan be set to the given
+// quantity q. The controller refuses quantities above maxServeQuantity in
+// serving mode.
 func trySetQuantity(q int) {
-	if q != 1 && c.GetMode() != stocking {
-		logAllInfo("Serving of multiple drinks at once is not supported")
-		return
-	}
-	if q == quantity {
-		return
-	}
-	quantity = q
-	logAllInfo("Quantity of drinks per scan changed to ", quantity)
+	c.Post(Event{Kind: quantityEvent, Quantity: q})
+}
 
+// updateKeybindHints redraws the keybinding hints, highlighting the given quantity.
+func updateKeybindHints(quantity int) {
 	v, _ := g.View(prompt)
 	v.Clear()
-	fmt.Fprintf(v, generateKeybindString(q))
+	fmt.Fprint(v, generateKeybindString(quantity))
 }
 
 // setQuantity1 prepares the controller for either the scanning or serving
@@ -0,0 +1,45 @@
+package model
+
+import "github.com/bhutch29/abv/config"
+
+// Backend stores drinks and inventory records. Model is the local SQLite
+// Backend and Client is a Backend which talks to the ABV API.
+type Backend interface {
+	BarcodeExists(bc string) (bool, error)
+	GetDrinkByBarcode(bc string) (Drink, error)
+	GetCountByBarcode(bc string) (int, error)
+	GetInventory() ([]StockedDrink, error)
+	GetInventorySorted(sortFields []string) ([]StockedDrink, error)
+	QueryInventory(q InventoryQuery) ([]StockedDrink, error)
+	GetInventoryTotalQuantity() (int, error)
+	GetInventoryTotalVariety() (int, error)
+	CreateDrink(d Drink) (int, error)
+	DeleteDrink(bc string) error
+	InputDrinks(d DrinkEntry) (int, error)
+	UndoInputDrinks(id int) error
+	OutputDrinks(d DrinkEntry) (int, error)
+	UndoOutputDrinks(id int) error
+	CorrectDrinks(d DrinkEntry) (int, error)
+	UndoCorrectDrinks(id int) error
+	ClearInputTable() error
+	ClearOutputTable() error
+	ClearCorrectionsTable() error
+	Authenticate(secret string) (Token, error)
+}
+
+// NewBackend returns a Client for the API at the configured backendUrl, or
+// the local SQLite database if no backendUrl is configured
+func NewBackend() (Backend, error) {
+	conf, err := config.New()
+	if err != nil {
+		return nil, err
+	}
+	if url := conf.GetString("backendUrl"); url != "" {
+		return NewClient(url, conf.GetString("apiToken")), nil
+	}
+	m, err := New()
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
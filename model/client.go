@@ -0,0 +1,258 @@
+package model
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client is a Backend which uses the ABV API over HTTP
+type Client struct {
+	url   string
+	token string
+	http  *http.Client
+}
+
+// NewClient returns a Client for the ABV API at the given URL, e.g.
+// http://192.168.0.100:8081, which authenticates with the given API token.
+// Stocking and serving need a token with the stock and serve scopes.
+func NewClient(url string, token string) *Client {
+	return &Client{
+		url:   strings.TrimSuffix(url, "/"),
+		token: token,
+		http:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// created is the response to requests which create a record
+type created struct {
+	ID int
+}
+
+// BarcodeExists checks if a barcode is already in the database
+func (c *Client) BarcodeExists(bc string) (bool, error) {
+	_, err := c.GetDrinkByBarcode(bc)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// GetDrinkByBarcode returns all stored information about a drink based on its barcode
+func (c *Client) GetDrinkByBarcode(bc string) (Drink, error) {
+	var d Drink
+	err := c.do("GET", "/drinks/"+url.PathEscape(bc), nil, &d)
+	return d, err
+}
+
+// GetCountByBarcode returns the total number of currently stocked beers with a specific barcode
+func (c *Client) GetCountByBarcode(bc string) (int, error) {
+	var count int
+	err := c.do("GET", "/drinks/"+url.PathEscape(bc)+"/count", nil, &count)
+	return count, err
+}
+
+// GetInventory returns every drink with at least one quantity in stock, sorted by Type
+func (c *Client) GetInventory() ([]StockedDrink, error) {
+	var drinks []StockedDrink
+	err := c.do("GET", "/inventory", nil, &drinks)
+	return drinks, err
+}
+
+// GetInventorySorted returns every drink with at least one quantity in stock, sorted by the provided Fields
+func (c *Client) GetInventorySorted(sortFields []string) ([]StockedDrink, error) {
+	return c.QueryInventory(InventoryQuery{Sort: sortFields})
+}
+
+// QueryInventory returns the drinks with at least one quantity in stock which match the query
+func (c *Client) QueryInventory(q InventoryQuery) ([]StockedDrink, error) {
+	var drinks []StockedDrink
+	if err := q.Validate(); err != nil {
+		return drinks, err
+	}
+	err := c.do("GET", "/inventory?"+q.Values().Encode(), nil, &drinks)
+	return drinks, err
+}
+
+// GetInventoryTotalQuantity returns the total number of beer bottles in stock
+func (c *Client) GetInventoryTotalQuantity() (int, error) {
+	var q int
+	err := c.do("GET", "/inventory/quantity", nil, &q)
+	return q, err
+}
+
+// GetInventoryTotalVariety returns the total number of beer varieties in stock
+func (c *Client) GetInventoryTotalVariety() (int, error) {
+	var q int
+	err := c.do("GET", "/inventory/variety", nil, &q)
+	return q, err
+}
+
+// CreateDrink adds an entry to the Drinks table, returning the id
+func (c *Client) CreateDrink(d Drink) (int, error) {
+	var res created
+	if err := c.do("POST", "/drinks", d, &res); err != nil {
+		return -1, err
+	}
+	return res.ID, nil
+}
+
+// DeleteDrink removes an entry from the Drinks table using its barcode
+func (c *Client) DeleteDrink(bc string) error {
+	return c.do("DELETE", "/drinks/"+url.PathEscape(bc), nil, nil)
+}
+
+// InputDrinks adds an entry to the Input table, returning the id
+func (c *Client) InputDrinks(d DrinkEntry) (int, error) {
+	var res created
+	if err := c.do("POST", "/input", d, &res); err != nil {
+		return -1, err
+	}
+	return res.ID, nil
+}
+
+// UndoInputDrinks removes an entry from the Input table by id
+func (c *Client) UndoInputDrinks(id int) error {
+	return c.do("DELETE", "/input/"+strconv.Itoa(id), nil, nil)
+}
+
+// OutputDrinks adds an entry to the Output table, returning the id
+func (c *Client) OutputDrinks(d DrinkEntry) (int, error) {
+	var res created
+	if err := c.do("POST", "/output", d, &res); err != nil {
+		return -1, err
+	}
+	return res.ID, nil
+}
+
+// UndoOutputDrinks removes an entry from the Output table by id
+func (c *Client) UndoOutputDrinks(id int) error {
+	return c.do("DELETE", "/output/"+strconv.Itoa(id), nil, nil)
+}
+
+// CorrectDrinks adds an entry to the Corrections table, returning the id
+func (c *Client) CorrectDrinks(d DrinkEntry) (int, error) {
+	var res created
+	if err := c.do("POST", "/corrections", d, &res); err != nil {
+		return -1, err
+	}
+	return res.ID, nil
+}
+
+// UndoCorrectDrinks removes an entry from the Corrections table by id
+func (c *Client) UndoCorrectDrinks(id int) error {
+	return c.do("DELETE", "/corrections/"+strconv.Itoa(id), nil, nil)
+}
+
+// ClearInputTable deletes all stocking records
+func (c *Client) ClearInputTable() error {
+	return c.do("DELETE", "/input", nil, nil)
+}
+
+// ClearOutputTable deletes all serving records
+func (c *Client) ClearOutputTable() error {
+	return c.do("DELETE", "/output", nil, nil)
+}
+
+// ClearCorrectionsTable deletes all count corrections
+func (c *Client) ClearCorrectionsTable() error {
+	return c.do("DELETE", "/corrections", nil, nil)
+}
+
+// SetDrinkLogo sets the custom logo of the drink with the given barcode to an
+// image, which the menu shows instead of its Untappd logo
+func (c *Client) SetDrinkLogo(bc string, img io.Reader) error {
+	return c.do("PUT", "/drinks/"+url.PathEscape(bc)+"/logo", img, nil)
+}
+
+// DeleteDrinkLogo removes the custom logo of the drink with the given barcode
+func (c *Client) DeleteDrinkLogo(bc string) error {
+	return c.do("DELETE", "/drinks/"+url.PathEscape(bc)+"/logo", nil, nil)
+}
+
+// SetBrandLogo sets the custom logo of every drink of a brand to an image
+func (c *Client) SetBrandLogo(brand string, img io.Reader) error {
+	return c.do("PUT", "/brands/"+url.PathEscape(brand)+"/logo", img, nil)
+}
+
+// DeleteBrandLogo removes the custom logo of a brand
+func (c *Client) DeleteBrandLogo(brand string) error {
+	return c.do("DELETE", "/brands/"+url.PathEscape(brand)+"/logo", nil, nil)
+}
+
+// Authenticate returns the API token with the given secret, or
+// ErrInvalidToken if the API does not know it
+func (c *Client) Authenticate(secret string) (Token, error) {
+	var t Token
+	err := (&Client{url: c.url, token: secret, http: c.http}).do("GET", "/token", nil, &t)
+	if errors.Is(err, ErrInvalidToken) {
+		return t, ErrInvalidToken
+	}
+	return t, err
+}
+
+// do sends a request with an optional body and decodes the JSON response into
+// val, if val is not nil. A body which is an io.Reader is sent as is, and
+// any other body is sent as JSON.
+//
+// A 404 Not Found response is reported as sql.ErrNoRows, like the local Model,
+// and a 401 Unauthorized response as ErrInvalidToken.
+func (c *Client) do(method, path string, body interface{}, val interface{}) error {
+	var buf bytes.Buffer
+	contentType := "application/json"
+	if r, ok := body.(io.Reader); ok {
+		if _, err := buf.ReadFrom(r); err != nil {
+			return err
+		}
+		contentType = "application/octet-stream"
+	} else if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, c.url+path, &buf)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return sql.ErrNoRows
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		return fmt.Errorf("%s %s: %w", method, path, ErrInvalidToken)
+	}
+	if resp.StatusCode >= 300 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		var e struct{ Error struct{ Message string } }
+		if json.Unmarshal(msg, &e) == nil && e.Error.Message != "" {
+			msg = []byte(e.Error.Message)
+		}
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if val == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(val)
+}
@@ -0,0 +1,226 @@
+package model
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// SortFields maps the fields the inventory can be sorted by to their
+// columns. Brands, names and short types are sorted by their nicknames.
+var SortFields = map[string]string{
+	"quantity":  "A.quantity",
+	"shorttype": "A.shown_shorttype",
+	"type":      "A.type",
+	"brand":     "A.shown_brand",
+	"name":      "A.shown_name",
+	"abv":       "A.abv",
+	"ibu":       "A.ibu",
+	"country":   "A.country",
+	"date":      "A.date",
+}
+
+// InventoryQuery filters, sorts and pages the stocked inventory. Zero values
+// are ignored.
+type InventoryQuery struct {
+	Sort    []string // fields from SortFields
+	Desc    bool
+	Style   string // part of the type or short type
+	Brand   string // part of the brand
+	Country string
+	MinAbv  *float64
+	MaxAbv  *float64
+	Search  string // part of the brand, name, type or country
+
+	MinQuantity int // the fewest in stock, for drinks which are nearly gone
+	Limit       int
+	Offset      int
+}
+
+// QueryError reports an invalid InventoryQuery
+type QueryError struct {
+	Param string
+	Value string
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("invalid %s: %q", e.Param, e.Value)
+}
+
+// ParseInventoryQuery reads an InventoryQuery from the URL query parameters
+// sort (comma separated fields), order (asc or desc), style, brand, country,
+// min_abv, max_abv, q, min_quantity, limit and offset
+func ParseInventoryQuery(values url.Values) (InventoryQuery, error) {
+	q := InventoryQuery{
+		Style:   values.Get("style"),
+		Brand:   values.Get("brand"),
+		Country: values.Get("country"),
+		Search:  values.Get("q"),
+	}
+	if s := values.Get("sort"); s != "" {
+		q.Sort = strings.Split(s, ",")
+	}
+
+	switch o := values.Get("order"); o {
+	case "", "asc":
+	case "desc":
+		q.Desc = true
+	default:
+		return q, &QueryError{"order", o}
+	}
+
+	for _, p := range []struct {
+		name string
+		dest **float64
+	}{{"min_abv", &q.MinAbv}, {"max_abv", &q.MaxAbv}} {
+		if s := values.Get(p.name); s != "" {
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return q, &QueryError{p.name, s}
+			}
+			*p.dest = &f
+		}
+	}
+
+	for _, p := range []struct {
+		name string
+		dest *int
+	}{{"min_quantity", &q.MinQuantity}, {"limit", &q.Limit}, {"offset", &q.Offset}} {
+		if s := values.Get(p.name); s != "" {
+			i, err := strconv.Atoi(s)
+			if err != nil || i < 0 {
+				return q, &QueryError{p.name, s}
+			}
+			*p.dest = i
+		}
+	}
+
+	return q, q.Validate()
+}
+
+// Validate checks that the query only uses known sort fields and valid numbers
+func (q InventoryQuery) Validate() error {
+	for _, f := range q.Sort {
+		if _, ok := SortFields[f]; !ok {
+			return &QueryError{"sort field", f}
+		}
+	}
+	if q.MinQuantity < 0 {
+		return &QueryError{"min_quantity", strconv.Itoa(q.MinQuantity)}
+	}
+	if q.Limit < 0 {
+		return &QueryError{"limit", strconv.Itoa(q.Limit)}
+	}
+	if q.Offset < 0 {
+		return &QueryError{"offset", strconv.Itoa(q.Offset)}
+	}
+	return nil
+}
+
+// Values encodes the query as URL query parameters for ParseInventoryQuery
+func (q InventoryQuery) Values() url.Values {
+	v := url.Values{}
+	set := func(key, value string) {
+		if value != "" {
+			v.Set(key, value)
+		}
+	}
+	set("sort", strings.Join(q.Sort, ","))
+	if q.Desc {
+		v.Set("order", "desc")
+	}
+	set("style", q.Style)
+	set("brand", q.Brand)
+	set("country", q.Country)
+	set("q", q.Search)
+	if q.MinAbv != nil {
+		v.Set("min_abv", strconv.FormatFloat(*q.MinAbv, 'f', -1, 64))
+	}
+	if q.MaxAbv != nil {
+		v.Set("max_abv", strconv.FormatFloat(*q.MaxAbv, 'f', -1, 64))
+	}
+	if q.MinQuantity > 0 {
+		v.Set("min_quantity", strconv.Itoa(q.MinQuantity))
+	}
+	if q.Limit > 0 {
+		v.Set("limit", strconv.Itoa(q.Limit))
+	}
+	if q.Offset > 0 {
+		v.Set("offset", strconv.Itoa(q.Offset))
+	}
+	return v
+}
+
+// sql returns the where, order by and limit clauses of the query and their
+// arguments. Style, brand and search filters match drinks by their stored
+// names and by their nicknames.
+func (q InventoryQuery) sql() (string, []interface{}) {
+	var clauses []string
+	var args []interface{}
+	like := func(s string) string {
+		return "%" + s + "%"
+	}
+
+	if q.Style != "" {
+		clauses = append(clauses, "(A.type like ? or A.shorttype like ? or A.shown_shorttype like ?)")
+		args = append(args, like(q.Style), like(q.Style), like(q.Style))
+	}
+	if q.Brand != "" {
+		clauses = append(clauses, "(A.brand like ? or A.shown_brand like ?)")
+		args = append(args, like(q.Brand), like(q.Brand))
+	}
+	if q.Country != "" {
+		clauses = append(clauses, "A.country = ? collate nocase")
+		args = append(args, q.Country)
+	}
+	if q.MinAbv != nil {
+		clauses = append(clauses, "A.abv >= ?")
+		args = append(args, *q.MinAbv)
+	}
+	if q.MaxAbv != nil {
+		clauses = append(clauses, "A.abv <= ?")
+		args = append(args, *q.MaxAbv)
+	}
+	if q.Search != "" {
+		columns := []string{"A.brand", "A.shown_brand", "A.name", "A.shown_name", "A.type", "A.shorttype", "A.shown_shorttype", "A.country"}
+		var matches []string
+		for _, c := range columns {
+			matches = append(matches, c+" like ?")
+			args = append(args, like(q.Search))
+		}
+		clauses = append(clauses, "("+strings.Join(matches, " or ")+")")
+	}
+	if q.MinQuantity > 0 {
+		clauses = append(clauses, "A.quantity >= ?")
+		args = append(args, q.MinQuantity)
+	}
+
+	var sql strings.Builder
+	for _, c := range clauses {
+		sql.WriteString("\nand " + c)
+	}
+
+	var columns []string
+	for _, f := range q.Sort {
+		column := SortFields[f]
+		if q.Desc {
+			column += " desc"
+		}
+		columns = append(columns, column)
+	}
+	// Drinks which sort the same are ordered by barcode, so that pages
+	// do not overlap
+	columns = append(columns, "A.barcode")
+	sql.WriteString("\norder by " + strings.Join(columns, ", "))
+
+	if q.Limit > 0 || q.Offset > 0 {
+		limit := q.Limit
+		if limit == 0 {
+			limit = -1
+		}
+		sql.WriteString("\nlimit ? offset ?")
+		args = append(args, limit, q.Offset)
+	}
+	return sql.String(), args
+}
@@ -0,0 +1,153 @@
+package model
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func newTestModel(t *testing.T) *Model {
+	conf := viper.New()
+	conf.Set("breweryNicknames", map[string]string{"bayerische staatsbrauerei weihenstephan": "Weihenstephaner"})
+	conf.Set("styleNicknames", map[string]string{"american wild ale": "Sour"})
+	m, err := Open(":memory:", conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { m.Close() })
+
+	for _, d := range []StockedDrink{
+		{Drink{Barcode: "1", Brand: "Bayerische Staatsbrauerei Weihenstephan", Name: "Hefeweissbier", Shorttype: "Hefeweizen", Abv: 5.4}, 4},
+		{Drink{Barcode: "2", Brand: "Allagash Brewing Company", Name: "Coolship Red", Shorttype: "American Wild Ale", Abv: 5.7}, 1},
+		{Drink{Barcode: "3", Brand: "Crooked Stave", Name: "Origins", Shorttype: "Sour", Abv: 6.5}, 2},
+		{Drink{Barcode: "4", Brand: "Firestone Walker Brewing Company", Name: "Union Jack", Shorttype: "IPA", Abv: 7.5}, 6},
+		{Drink{Barcode: "5", Brand: "Zed's", Name: "Gone", Shorttype: "Sour"}, 0},
+	} {
+		if _, err := m.CreateDrink(d.Drink); err != nil {
+			t.Fatal(err)
+		}
+		if d.Quantity > 0 {
+			m.InputDrinks(DrinkEntry{Barcode: d.Barcode, Quantity: d.Quantity})
+		}
+	}
+	return &m
+}
+
+func TestQueryInventory(t *testing.T) {
+	m := newTestModel(t)
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"sort=shorttype,brand", []string{"1", "4", "2", "3"}},
+		{"sort=brand", []string{"2", "3", "4", "1"}},
+		{"sort=brand&order=desc", []string{"1", "4", "3", "2"}},
+		{"sort=quantity,name", []string{"2", "3", "1", "4"}},
+		{"sort=abv&order=desc", []string{"4", "3", "2", "1"}},
+		{"style=sour&sort=name", []string{"2", "3"}},
+		{"style=wild", []string{"2"}},
+		{"brand=weihenstephaner", []string{"1"}},
+		{"q=SOUR&sort=brand", []string{"2", "3"}},
+		{"q=staatsbrauerei", []string{"1"}},
+		{"min_quantity=4&sort=quantity", []string{"1", "4"}},
+		{"max_abv=6&sort=abv", []string{"1", "2"}},
+		{"sort=brand&limit=2", []string{"2", "3"}},
+		{"sort=brand&limit=2&offset=2", []string{"4", "1"}},
+		{"sort=brand&offset=3", []string{"1"}},
+		{"sort=brand&offset=9", nil},
+		{"sort=shorttype&limit=1&offset=2", []string{"2"}},
+		{"sort=shorttype&limit=1&offset=3", []string{"3"}},
+	}
+	for _, tt := range tests {
+		values, _ := url.ParseQuery(tt.query)
+		q, err := ParseInventoryQuery(values)
+		if err != nil {
+			t.Errorf("%s: %v", tt.query, err)
+			continue
+		}
+		drinks, err := m.QueryInventory(q)
+		if err != nil {
+			t.Errorf("%s: %v", tt.query, err)
+			continue
+		}
+		var got []string
+		for _, d := range drinks {
+			got = append(got, d.Barcode)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got drinks %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestQueryInventoryNicknames(t *testing.T) {
+	m := newTestModel(t)
+	drinks, err := m.QueryInventory(InventoryQuery{Sort: []string{"shorttype"}, Style: "sour"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range drinks {
+		if d.Shorttype != "Sour" {
+			t.Errorf("drink %s has style %q, want Sour", d.Barcode, d.Shorttype)
+		}
+	}
+	if d, _ := m.GetDrinkByBarcode("2"); d.Brand != "Allagash" || d.StoredBrand != "Allagash Brewing Company" {
+		t.Errorf("brand is %q stored as %q, want Allagash stored as Allagash Brewing Company", d.Brand, d.StoredBrand)
+	}
+	drinks, err = m.QueryInventory(InventoryQuery{Brand: "weihenstephaner"})
+	if err != nil || len(drinks) != 1 || drinks[0].StoredBrand != "Bayerische Staatsbrauerei Weihenstephan" {
+		t.Errorf("got %+v, %v, want the stored brand of drink 1", drinks, err)
+	}
+}
+
+func TestParseInventoryQueryErrors(t *testing.T) {
+	for _, query := range []string{"sort=color", "order=up", "min_abv=strong", "limit=-1", "offset=x", "min_quantity=-2"} {
+		values, _ := url.ParseQuery(query)
+		if _, err := ParseInventoryQuery(values); err == nil {
+			t.Errorf("%s was accepted", query)
+		}
+	}
+}
+
+func TestCorrections(t *testing.T) {
+	m := newTestModel(t)
+	m.OutputDrinks(DrinkEntry{Barcode: "3", Quantity: 1})
+	m.CorrectDrinks(DrinkEntry{Barcode: "3", Quantity: -1})
+	m.CorrectDrinks(DrinkEntry{Barcode: "5", Quantity: 2})
+
+	for bc, want := range map[string]int{"3": 0, "5": 2} {
+		if count, err := m.GetCountByBarcode(bc); err != nil || count != want {
+			t.Errorf("count of %s = %d, %v, want %d", bc, count, err, want)
+		}
+	}
+	if total, err := m.GetInventoryTotalQuantity(); err != nil || total != 13 {
+		t.Errorf("total quantity = %d, %v, want 13", total, err)
+	}
+	if variety, err := m.GetInventoryTotalVariety(); err != nil || variety != 4 {
+		t.Errorf("total variety = %d, %v, want 4", variety, err)
+	}
+	history, err := m.GetHistoryByBarcode("3")
+	if err != nil || len(history) != 3 {
+		t.Fatalf("history of 3 = %+v, %v", history, err)
+	}
+	for _, tr := range history {
+		if tr.Kind == "correction" && tr.Quantity != -1 {
+			t.Errorf("correction of 3 = %+v, want quantity -1", tr)
+		}
+	}
+}
+
+func TestGetDrinksCreatedSince(t *testing.T) {
+	m := newTestModel(t)
+	drinks, err := m.GetDrinksCreatedSince(0)
+	if err != nil || len(drinks) != 5 {
+		t.Errorf("got %d drinks, %v, want 5", len(drinks), err)
+	}
+	drinks, err = m.GetDrinksCreatedSince(Date(time.Now().Add(time.Hour).Unix()))
+	if err != nil || len(drinks) != 0 {
+		t.Errorf("got %d drinks created in the future, %v", len(drinks), err)
+	}
+}
@@ -2,6 +2,7 @@
 package model
 
 import (
+	"fmt"
 	"os"
 
 	"github.com/bhutch29/abv/config"
@@ -19,34 +20,74 @@ type Model struct {
 	conf *viper.Viper
 }
 
-// New creates a new fully initialized Model
+// New creates a new fully initialized Model for the abv.sqlite database in
+// the configured configPath
 func New() (Model, error) {
-	model := Model{}
 	conf, err := config.New()
 	if err != nil {
-		return model, err
+		return Model{}, err
 	}
-	model.conf = conf
 
 	configPath, _ := homedir.Expand((conf.GetString("configPath")))
 	file := configPath + "/abv.sqlite"
 	if _, err := os.Stat(file); os.IsNotExist(err) {
-		os.Create(file)
+		if f, err := os.Create(file); err == nil {
+			f.Close()
+		}
 	}
 
+	return Open(file, conf)
+}
+
+// Open creates a new fully initialized Model for the given SQLite database
+// file, using conf for nicknames. The file ":memory:" opens a private
+// in-memory database, which is useful for tests.
+func Open(file string, conf *viper.Viper) (Model, error) {
+	model := Model{conf: conf}
+
 	db, err := sqlx.Open("sqlite3", file)
 	if err != nil {
 		return model, err
 	}
+	if file == ":memory:" {
+		// Every connection to :memory: opens a different database
+		db.SetMaxOpenConns(1)
+	}
 
 	model.db = db
-	model.CreateTablesIfNeeded()
-	return model, nil
+	err = model.CreateTablesIfNeeded()
+	return model, err
+}
+
+// SchemaVersion is the version of the database tables created by this
+// version of ABV. It is stored as the SQLite user_version.
+//
+//	1: Drinks, Input and Output
+//	2: scanner column in Input and Output
+//	3: Tokens
+//	4: Corrections
+const SchemaVersion = 4
+
+// Ping checks that the database can be reached
+func (m *Model) Ping() error {
+	return m.db.Ping()
+}
+
+// GetSchemaVersion returns the schema version of the database
+func (m *Model) GetSchemaVersion() (int, error) {
+	var v int
+	err := m.db.Get(&v, "pragma user_version")
+	return v, err
+}
+
+// Close closes the database
+func (m *Model) Close() error {
+	return m.db.Close()
 }
 
 // CreateTablesIfNeeded ensures that the db has the necessary tables
-func (m *Model) CreateTablesIfNeeded() {
-	m.db.Exec(`
+func (m *Model) CreateTablesIfNeeded() error {
+	_, err := m.db.Exec(`
 create table if not exists Drinks (
 barcode varchar(255) primary key,
 brand varchar(255),
@@ -59,20 +100,74 @@ logo varchar(255),
 country varchar(255),
 date integer)
 `)
-	m.db.Exec(`
+	if err != nil {
+		return err
+	}
+	_, err = m.db.Exec(`
 create table if not exists Input (
 id integer primary key,
 barcode varchar(255),
 quantity integer,
-date integer)
+date integer,
+scanner varchar(255))
 `)
-	m.db.Exec(`
+	if err != nil {
+		return err
+	}
+	_, err = m.db.Exec(`
 create table if not exists Output (
 id integer primary key,
 barcode varchar(255),
 quantity integer,
-date integer)
+date integer,
+scanner varchar(255))
+`)
+	if err != nil {
+		return err
+	}
+	_, err = m.db.Exec(`
+create table if not exists Corrections (
+id integer primary key,
+barcode varchar(255),
+quantity integer,
+date integer,
+scanner varchar(255))
 `)
+	if err != nil {
+		return err
+	}
+	_, err = m.db.Exec(`
+create table if not exists Tokens (
+id integer primary key,
+name varchar(255) unique,
+hash varchar(64) unique,
+scopes varchar(255),
+created integer)
+`)
+	if err != nil {
+		return err
+	}
+	if err = m.addColumnIfNeeded("Input", "scanner", "varchar(255)"); err != nil {
+		return err
+	}
+	if err = m.addColumnIfNeeded("Output", "scanner", "varchar(255)"); err != nil {
+		return err
+	}
+	_, err = m.db.Exec(fmt.Sprintf("pragma user_version = %d", SchemaVersion))
+	return err
+}
+
+// addColumnIfNeeded adds a column to tables created by older versions of ABV
+func (m *Model) addColumnIfNeeded(table, column, columnType string) error {
+	var count int
+	if err := m.db.Get(&count, "select count(*) from pragma_table_info(?) where name = ?", table, column); err != nil {
+		return err
+	}
+	if count == 0 {
+		_, err := m.db.Exec("alter table " + table + " add column " + column + " " + columnType)
+		return err
+	}
+	return nil
 }
 
 // Date is a representation of a Unix time stamp
@@ -96,6 +191,11 @@ type Drink struct {
 	Logo      string
 	Date      Date
 	Country   string
+
+	// StoredBrand is the brand before nicknames are applied. Custom brand
+	// logos are kept under it, so they still match after breweryNicknames
+	// change.
+	StoredBrand string `db:"stored_brand"`
 }
 
 // DrinkEntry defines quantities of drinks for transactions
@@ -103,6 +203,19 @@ type DrinkEntry struct {
 	Barcode  string
 	Quantity int
 	Date     Date
+	Scanner  string
+}
+
+// Transaction is a record of drinks being stocked ("input"), served
+// ("output") or counted ("correction"). The quantity of a correction is
+// negative if fewer drinks were counted than were in stock.
+type Transaction struct {
+	ID       int
+	Kind     string
+	Barcode  string
+	Quantity int
+	Date     Date
+	Scanner  string
 }
 
 // StockedDrink is an extension of drink with an additional field for quantity
@@ -2,8 +2,6 @@ package model
 
 import "database/sql"
 import "strings"
-import "sort"
-import "log"
 
 // BarcodeExists checks if a barcode is already in the database
 func (m *Model) BarcodeExists(bc string) (bool, error) {
@@ -26,70 +24,80 @@ func (m *Model) GetAllStoredDrinks() ([]Drink, error) {
 	return drinks, err
 }
 
-// TODO: These DrinkNickname methods are pretty awful, lots of repeated code. How to improve?
+// GetDrinksCreatedSince returns every Drink row created at or after the given date
+func (m *Model) GetDrinksCreatedSince(date Date) ([]Drink, error) {
+	var drinks []Drink
+	err := m.db.Select(&drinks, "select * from Drinks where date >= ?", date)
+	drinks = m.setDrinksNicknames(drinks)
+	return drinks, err
+}
+
+// nicknames maps stored drink values to the names they are displayed with,
+// from the breweryNicknames, beerNicknames and styleNicknames settings
+type nicknames struct {
+	brands, names, styles map[string]string
+}
+
+func (m *Model) nicknames() nicknames {
+	return nicknames{
+		brands: m.conf.GetStringMapString("breweryNicknames"),
+		names:  m.conf.GetStringMapString("beerNicknames"),
+		styles: m.conf.GetStringMapString("styleNicknames"),
+	}
+}
+
+// brand returns the nickname of a brand, shortened by shortenBrand
+func (n nicknames) brand(s string) string {
+	return shortenBrand(nickname(n.brands, s))
+}
+
+func (n nicknames) name(s string) string {
+	return nickname(n.names, s)
+}
+
+func (n nicknames) style(s string) string {
+	return nickname(n.styles, s)
+}
+
+func (n nicknames) drink(drink Drink) Drink {
+	if drink.StoredBrand == "" {
+		drink.StoredBrand = drink.Brand
+	}
+	drink.Brand = n.brand(drink.Brand)
+	drink.Name = n.name(drink.Name)
+	drink.Shorttype = n.style(drink.Shorttype)
+	return drink
+}
+
+// nickname looks up a value in a nickname table, whose keys are lowercase
+func nickname(nicks map[string]string, s string) string {
+	if nick, ok := nicks[strings.ToLower(s)]; ok {
+		return nick
+	}
+	return s
+}
+
 func (m *Model) setDrinksNicknames(drinks []Drink) []Drink {
-	brandNicks := m.conf.GetStringMapString("breweryNicknames")
-	beerNicks := m.conf.GetStringMapString("beerNicknames")
-	styleNicks := m.conf.GetStringMapString("styleNicknames")
+	n := m.nicknames()
 	var result []Drink
 	for _, drink := range drinks {
-		if nick, ok := brandNicks[strings.ToLower(drink.Brand)]; ok {
-			drink.Brand = nick
-		}
-		if nick, ok := beerNicks[strings.ToLower(drink.Name)]; ok {
-			drink.Name = nick
-		}
-		if nick, ok := styleNicks[strings.ToLower(drink.Shorttype)]; ok {
-			drink.Shorttype = nick
-		}
-
-		drink.Brand = shortenBrand(drink.Brand)
-
-		result = append(result, drink)
+		result = append(result, n.drink(drink))
 	}
 	return result
 }
 
 func (m *Model) setStockedDrinksNicknames(drinks []StockedDrink) []StockedDrink {
-	brandNicks := m.conf.GetStringMapString("breweryNicknames")
-	beerNicks := m.conf.GetStringMapString("beerNicknames")
-	styleNicks := m.conf.GetStringMapString("styleNicknames")
+	n := m.nicknames()
 	var result []StockedDrink
 	for _, drink := range drinks {
-		if nick, ok := brandNicks[strings.ToLower(drink.Brand)]; ok {
-			drink.Brand = nick
-		}
-		if nick, ok := beerNicks[strings.ToLower(drink.Name)]; ok {
-			drink.Name = nick
-		}
-		if nick, ok := styleNicks[strings.ToLower(drink.Shorttype)]; ok {
-			drink.Shorttype = nick
-		}
-
-		drink.Brand = shortenBrand(drink.Brand)
-
+		drink.Drink = n.drink(drink.Drink)
 		result = append(result, drink)
 	}
 	return result
 }
 
 func (m *Model) setDrinkNickname(drink Drink) Drink {
-	brandNicks := m.conf.GetStringMapString("breweryNicknames")
-	beerNicks := m.conf.GetStringMapString("beerNicknames")
-	styleNicks := m.conf.GetStringMapString("styleNicknames")
-	if nick, ok := brandNicks[strings.ToLower(drink.Brand)]; ok {
-		drink.Brand = nick
-	}
-	if nick, ok := beerNicks[strings.ToLower(drink.Name)]; ok {
-		drink.Name = nick
-	}
-	if nick, ok := styleNicks[strings.ToLower(drink.Shorttype)]; ok {
-		drink.Shorttype = nick
-	}
-
-	drink.Brand = shortenBrand(drink.Brand)
-
-	return drink
+	return m.nicknames().drink(drink)
 }
 
 func shortenBrand(in string) string {
@@ -110,15 +118,18 @@ func shortenBrand(in string) string {
 
 // GetCountByBarcode returns the total number of currently stocked beers with a specific barcode
 func (m *Model) GetCountByBarcode(bc string) (int, error) {
-	var input, output int
+	var input, output, correction int
 	if err := m.db.Get(&input, "select case when sum(quantity)is null then 0 else sum(quantity) end quantity from Input where barcode = ?", bc); err != nil {
 		return -1, err
 	}
 	if err := m.db.Get(&output, "select case when sum(quantity) is null then 0 else sum(quantity) end quantity from Output where barcode = ?", bc); err != nil {
 		return -1, err
 	}
+	if err := m.db.Get(&correction, "select coalesce(sum(quantity), 0) from Corrections where barcode = ?", bc); err != nil {
+		return -1, err
+	}
 
-	return input - output, nil
+	return input - output + correction, nil
 }
 
 // GetDrinkByBarcode returns all stored information about a drink based on its barcode
@@ -146,6 +157,9 @@ select (
     else sum(quantity)
   end
   from Output
+) + (
+  select coalesce(sum(quantity), 0)
+  from Corrections
 )
 `
 	err := m.db.Get(&result, sql)
@@ -155,34 +169,7 @@ select (
 // GetInventoryTotalVariety returns the total number of beer varieties in stock
 func (m *Model) GetInventoryTotalVariety() (int, error) {
 	var result int
-
-	sql := `
-select count(barcode) from (
-  select A.*,
-    case
-      when B.InputQuantity is null then 0
-      when C.OutputQuantity is null then B.InputQuantity
-      else (B.InputQuantity - C.OutputQuantity)
-    end as quantity
-  from Drinks as A
-
-  left join (
-    select barcode, sum(quantity) as InputQuantity
-    from Input
-    group by barcode
-  ) as B
-  on A.Barcode = B.Barcode
-
-  left join (
-    select barcode, sum(quantity) as OutputQuantity
-    from Output
-    group by barcode
-  ) as C
-  on A.Barcode = C.Barcode
-
-  where quantity > 0
-)`
-
+	sql := "select count(barcode) from (" + stockedDrinksSQL + "\nwhere quantity > 0)"
 	err := m.db.Get(&result, sql)
 	return result, err
 }
@@ -194,14 +181,83 @@ func (m *Model) GetInventory() ([]StockedDrink, error) {
 
 // GetInventorySorted returns every drink with at least one quantity in stock, sorted by the provided Fields
 func (m *Model) GetInventorySorted(sortFields []string) ([]StockedDrink, error) {
+	return m.QueryInventory(InventoryQuery{Sort: sortFields})
+}
+
+// QueryInventory returns the drinks with at least one quantity in stock
+// which match the query. Filtering, sorting and paging are done by the
+// database, using the names drinks are displayed with after nicknames are
+// applied. Filters match the stored names as well.
+func (m *Model) QueryInventory(q InventoryQuery) ([]StockedDrink, error) {
 	var result []StockedDrink
+	if err := q.Validate(); err != nil {
+		return result, err
+	}
 
+	columns, args, err := m.shownColumnsSQL()
+	if err != nil {
+		return result, err
+	}
 	sql := `
+select barcode, shown_brand as brand, shown_name as name, abv, ibu, type,
+  shown_shorttype as shorttype, logo, country, date, brand as stored_brand, quantity
+from (
+  select A.*, ` + columns + `
+  from (` + stockedDrinksSQL + `) as A
+) as A
+where A.quantity > 0`
+	clauses, clauseArgs := q.sql()
+	err = m.db.Select(&result, sql+clauses, append(args, clauseArgs...)...)
+	return result, err
+}
+
+// shownColumnsSQL returns the shown_brand, shown_name and shown_shorttype
+// columns, which have the names drinks are displayed with, and their
+// arguments. Nicknames are looked up for every distinct stored value and
+// mapped to it with a case expression.
+func (m *Model) shownColumnsSQL() (string, []interface{}, error) {
+	n := m.nicknames()
+	var columns []string
+	var args []interface{}
+	for _, c := range []struct {
+		name     string
+		nickname func(string) string
+	}{{"brand", n.brand}, {"name", n.name}, {"shorttype", n.style}} {
+		var values []string
+		if err := m.db.Select(&values, "select distinct "+c.name+" from Drinks where "+c.name+" is not null"); err != nil {
+			return "", nil, err
+		}
+		var cases strings.Builder
+		for _, v := range values {
+			if nick := c.nickname(v); nick != v {
+				cases.WriteString(" when ? then ?")
+				args = append(args, v, nick)
+			}
+		}
+		column := "A." + c.name
+		if cases.Len() > 0 {
+			column = "case " + column + cases.String() + " else " + column + " end"
+		}
+		columns = append(columns, column+" as shown_"+c.name)
+	}
+	return strings.Join(columns, ", "), args, nil
+}
+
+// GetCatalog returns every stored drink with its current quantity, including
+// drinks which are out of stock, sorted by brand and name
+func (m *Model) GetCatalog() ([]StockedDrink, error) {
+	var result []StockedDrink
+	err := m.db.Select(&result, stockedDrinksSQL+"\norder by A.brand, A.name")
+	result = m.setStockedDrinksNicknames(result)
+	return result, err
+}
+
+// stockedDrinksSQL selects every drink with its current quantity
+const stockedDrinksSQL = `
 select A.*,
   case
-    when B.InputQuantity is null then 0
-    when C.OutputQuantity is null then B.InputQuantity
-    else (B.InputQuantity - C.OutputQuantity)
+    when B.InputQuantity is null and D.Correction is null then 0
+    else coalesce(B.InputQuantity, 0) - coalesce(C.OutputQuantity, 0) + coalesce(D.Correction, 0)
   end as quantity
 from Drinks as A
 
@@ -219,34 +275,36 @@ left join (
 ) as C
 on A.Barcode = C.Barcode
 
-where quantity > 0`
+left join (
+  select barcode, sum(quantity) as Correction
+  from Corrections
+  group by barcode
+) as D
+on A.Barcode = D.Barcode`
 
-	err := m.db.Select(&result, sql)
-	result = m.setStockedDrinksNicknames(result)
-	result = m.sortByFields(result, sortFields)
+// GetHistoryByBarcode returns every stocking, serving and count correction record of a drink, oldest first
+func (m *Model) GetHistoryByBarcode(bc string) ([]Transaction, error) {
+	var result []Transaction
+	sql := `
+select id, 'input' as kind, barcode, quantity, date, coalesce(scanner, '') as scanner
+from Input where barcode = ?
+union all
+select id, 'output' as kind, barcode, quantity, date, coalesce(scanner, '') as scanner
+from Output where barcode = ?
+union all
+select id, 'correction' as kind, barcode, quantity, date, coalesce(scanner, '') as scanner
+from Corrections where barcode = ?
+order by date, kind, id
+`
+	err := m.db.Select(&result, sql, bc, bc, bc)
 	return result, err
 }
 
-func (m *Model) sortByFields(drinks []StockedDrink, sortFields []string) []StockedDrink {
-	for index := len(sortFields) - 1; index >= 0; index-- {
-		sort.SliceStable(drinks, func(i, j int) bool {
-			var result bool
-			switch sortFields[index] {
-			case "quantity":
-				result = drinks[i].Quantity < drinks[j].Quantity
-			case "shorttype":
-				result = drinks[i].Shorttype < drinks[j].Shorttype
-			case "brand":
-				result = drinks[i].Brand < drinks[j].Brand
-			case "name":
-				result = drinks[i].Name < drinks[j].Name
-			default:
-				log.Fatal(sortFields, drinks)
-			}
-			return result
-		})
-	}
-	return drinks
+// GetServedSince returns the number of drinks served since the given date
+func (m *Model) GetServedSince(date Date) (int, error) {
+	var result int
+	err := m.db.Get(&result, "select coalesce(sum(quantity), 0) from Output where date >= ?", date)
+	return result, err
 }
 
 // GetInputWithinDateRange returns every drink inputted within a date range, inclusive
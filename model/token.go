@@ -0,0 +1,117 @@
+package model
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// The scopes an API token can be granted. The admin scope allows everything.
+const (
+	ScopeMenu  = "menu"
+	ScopeServe = "serve"
+	ScopeStock = "stock"
+	ScopeAdmin = "admin"
+)
+
+// Scopes are all the valid token scopes
+var Scopes = []string{ScopeMenu, ScopeServe, ScopeStock, ScopeAdmin}
+
+// ErrInvalidToken is returned when authenticating with an unknown token
+var ErrInvalidToken = errors.New("invalid API token")
+
+// Token is a named API token. Only a hash of the secret token is stored.
+type Token struct {
+	ID      int
+	Name    string
+	Hash    string `json:"-"`
+	Scopes  string // comma separated
+	Created Date
+}
+
+// Allows returns whether the token has been granted the scope
+func (t Token) Allows(scope string) bool {
+	for _, s := range strings.Split(t.Scopes, ",") {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateToken stores a new token with the given name and scopes, returning
+// the secret token. The secret cannot be retrieved later.
+func (m *Model) CreateToken(name string, scopes []string) (string, error) {
+	if name == "" {
+		return "", errors.New("token name must not be empty")
+	}
+	if len(scopes) == 0 {
+		return "", errors.New("token must have at least one scope")
+	}
+	for _, s := range scopes {
+		if !validScope(s) {
+			return "", fmt.Errorf("unknown scope %q, expected one of %s", s, strings.Join(Scopes, ", "))
+		}
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	secret := hex.EncodeToString(b)
+
+	_, err := m.db.Exec("insert into Tokens (name, hash, scopes, created) Values (?, ?, ?, ?)",
+		name, hashToken(secret), strings.Join(scopes, ","), time.Now().Unix())
+	if err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// GetTokens returns every stored token
+func (m *Model) GetTokens() ([]Token, error) {
+	var tokens []Token
+	err := m.db.Select(&tokens, "select * from Tokens order by name")
+	return tokens, err
+}
+
+// DeleteToken revokes the token with the given name
+func (m *Model) DeleteToken(name string) error {
+	res, err := m.db.Exec("delete from Tokens where name = ?", name)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Authenticate returns the token matching the secret, or ErrInvalidToken
+func (m *Model) Authenticate(secret string) (Token, error) {
+	var t Token
+	err := m.db.Get(&t, "select * from Tokens where hash = ?", hashToken(secret))
+	if err == sql.ErrNoRows {
+		return t, ErrInvalidToken
+	}
+	return t, err
+}
+
+func hashToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func validScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
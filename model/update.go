@@ -3,8 +3,35 @@ package model
 import (
 	"database/sql"
 	"time"
+
+	"github.com/jmoiron/sqlx"
 )
 
+// Tx is a database transaction. Changes made through a Tx are only visible
+// to the Model once it is committed.
+type Tx struct {
+	tx *sqlx.Tx
+}
+
+// Begin starts a transaction
+func (m *Model) Begin() (*Tx, error) {
+	tx, err := m.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{tx}, nil
+}
+
+// Commit makes the changes of the transaction permanent
+func (t *Tx) Commit() error {
+	return t.tx.Commit()
+}
+
+// Rollback discards the changes of the transaction
+func (t *Tx) Rollback() error {
+	return t.tx.Rollback()
+}
+
 // ClearInputTable deletes all stocking records
 func (m *Model) ClearInputTable() error {
 	_, err := m.db.Exec("delete from Input")
@@ -17,10 +44,25 @@ func (m *Model) ClearOutputTable() error {
 	return err
 }
 
+// ClearCorrectionsTable deletes all count corrections
+func (m *Model) ClearCorrectionsTable() error {
+	_, err := m.db.Exec("delete from Corrections")
+	return err
+}
+
 // CreateDrink adds an entry to the Drinks table, returning the id
 func (m *Model) CreateDrink(d Drink) (int, error) {
+	return createDrink(m.db, d)
+}
+
+// CreateDrink adds an entry to the Drinks table, returning the id
+func (t *Tx) CreateDrink(d Drink) (int, error) {
+	return createDrink(t.tx, d)
+}
+
+func createDrink(e sqlx.Execer, d Drink) (int, error) {
 	now := time.Now().Unix()
-	res, err := m.db.Exec(
+	res, err := e.Exec(
 		"insert into Drinks (barcode, brand, name, abv, ibu, type, shorttype, logo, country, date) Values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", d.Barcode, d.Brand, d.Name, d.Abv, d.Ibu, d.Type, d.Shorttype, d.Logo, d.Country, now)
 	if err != nil {
 		return -1, err
@@ -30,41 +72,95 @@ func (m *Model) CreateDrink(d Drink) (int, error) {
 
 // DeleteDrink removes an entry from the Drinks table using its barcode
 func (m *Model) DeleteDrink(bc string) error {
-	_, err := m.db.Exec("delete from Drinks where barcode = ?", bc)
+	return deleteDrink(m.db, bc)
+}
+
+// DeleteDrink removes an entry from the Drinks table using its barcode
+func (t *Tx) DeleteDrink(bc string) error {
+	return deleteDrink(t.tx, bc)
+}
+
+func deleteDrink(e sqlx.Execer, bc string) error {
+	_, err := e.Exec("delete from Drinks where barcode = ?", bc)
 	return err
 }
 
 // InputDrinks adds an entry to the Input table, returning the id
 func (m *Model) InputDrinks(d DrinkEntry) (int, error) {
-	now := time.Now().Unix()
-	res, err := m.db.Exec(
-		"insert into Input (barcode, quantity, date) Values (?, ?, ?)", d.Barcode, d.Quantity, now)
-	if err != nil {
-		return -1, err
-	}
-	return getID(res)
+	return insertEntry(m.db, "Input", d)
+}
+
+// InputDrinks adds an entry to the Input table, returning the id
+func (t *Tx) InputDrinks(d DrinkEntry) (int, error) {
+	return insertEntry(t.tx, "Input", d)
 }
 
 // UndoInputDrinks removes an entry from the Input table by id
 func (m *Model) UndoInputDrinks(id int) error {
-	_, err := m.db.Exec("delete from Input where id = ?", id)
-	return err
+	return deleteEntry(m.db, "Input", id)
+}
+
+// UndoInputDrinks removes an entry from the Input table by id
+func (t *Tx) UndoInputDrinks(id int) error {
+	return deleteEntry(t.tx, "Input", id)
 }
 
 // OutputDrinks adds an entry to the Output table, returning the id
 func (m *Model) OutputDrinks(d DrinkEntry) (int, error) {
+	return insertEntry(m.db, "Output", d)
+}
+
+// OutputDrinks adds an entry to the Output table, returning the id
+func (t *Tx) OutputDrinks(d DrinkEntry) (int, error) {
+	return insertEntry(t.tx, "Output", d)
+}
+
+// UndoOutputDrinks removes an entry from the Output table by id
+func (m *Model) UndoOutputDrinks(id int) error {
+	return deleteEntry(m.db, "Output", id)
+}
+
+// UndoOutputDrinks removes an entry from the Output table by id
+func (t *Tx) UndoOutputDrinks(id int) error {
+	return deleteEntry(t.tx, "Output", id)
+}
+
+// CorrectDrinks adds an entry to the Corrections table, returning the id.
+// The quantity is added to the stock of the drink and is negative if fewer
+// drinks were counted than were in stock.
+func (m *Model) CorrectDrinks(d DrinkEntry) (int, error) {
+	return insertEntry(m.db, "Corrections", d)
+}
+
+// CorrectDrinks adds an entry to the Corrections table, returning the id
+func (t *Tx) CorrectDrinks(d DrinkEntry) (int, error) {
+	return insertEntry(t.tx, "Corrections", d)
+}
+
+// UndoCorrectDrinks removes an entry from the Corrections table by id
+func (m *Model) UndoCorrectDrinks(id int) error {
+	return deleteEntry(m.db, "Corrections", id)
+}
+
+// UndoCorrectDrinks removes an entry from the Corrections table by id
+func (t *Tx) UndoCorrectDrinks(id int) error {
+	return deleteEntry(t.tx, "Corrections", id)
+}
+
+// insertEntry adds an entry to the Input, Output or Corrections table, returning the id
+func insertEntry(e sqlx.Execer, table string, d DrinkEntry) (int, error) {
 	now := time.Now().Unix()
-	res, err := m.db.Exec(
-		"insert into Output (barcode, quantity, date) Values (?, ?, ?)", d.Barcode, d.Quantity, now)
+	res, err := e.Exec(
+		"insert into "+table+" (barcode, quantity, date, scanner) Values (?, ?, ?, ?)", d.Barcode, d.Quantity, now, d.Scanner)
 	if err != nil {
 		return -1, err
 	}
 	return getID(res)
 }
 
-// UndoOutputDrinks removes an entry from the Output table by id
-func (m *Model) UndoOutputDrinks(id int) error {
-	_, err := m.db.Exec("delete from Output where id = ?", id)
+// deleteEntry removes an entry from the Input, Output or Corrections table by id
+func deleteEntry(e sqlx.Execer, table string, id int) error {
+	_, err := e.Exec("delete from "+table+" where id = ?", id)
 	return err
 }
 
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bhutch29/abv/model"
+)
+
+// reconnectDelay is how long to wait before reconnecting to a headless abv
+// after losing the result stream.
+const reconnectDelay = 5 * time.Second
+
+// remoteStation is a Station backed by the station endpoint of a headless abv.
+//
+// Events are sent in order by a single goroutine. Errors talking to the
+// headless abv are reported as results on the subscribed channel.
+type remoteStation struct {
+	url     string
+	token   string
+	client  *http.Client
+	events  chan Event
+	results chan Result
+	once    sync.Once
+}
+
+// newRemoteStation returns a Station which drives the headless abv at addr,
+// sending events with the given API token.
+func newRemoteStation(addr string, token string) *remoteStation {
+	s := &remoteStation{
+		url:     "http://" + addr,
+		token:   token,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		events:  make(chan Event, 16),
+		results: make(chan Result, 64),
+	}
+	go s.send()
+	return s
+}
+
+// Post implements the Station interface
+func (s *remoteStation) Post(e Event) {
+	s.events <- e
+}
+
+// Status implements the Station interface
+func (s *remoteStation) Status() Status {
+	var status stationStatus
+	if err := s.get("/status", &status); err != nil {
+		logAllError("Could not get status of headless abv: ", err)
+	}
+	return status.Status
+}
+
+// Subscribe implements the Station interface. Only a single subscriber is supported.
+func (s *remoteStation) Subscribe() <-chan Result {
+	s.once.Do(func() { go s.stream() })
+	return s.results
+}
+
+// GetInventorySorted implements the Station interface
+func (s *remoteStation) GetInventorySorted(sortFields []string) []model.StockedDrink {
+	var drinks []model.StockedDrink
+	q := url.Values{"sort": {strings.Join(sortFields, ",")}}
+	if err := s.get("/inventory?"+q.Encode(), &drinks); err != nil {
+		logAllError("Error getting current inventory: ", err)
+	}
+	return drinks
+}
+
+// GetInventoryTotalQuantity implements the Station interface
+func (s *remoteStation) GetInventoryTotalQuantity() int {
+	var q int
+	if err := s.get("/inventory/quantity", &q); err != nil {
+		logAllError("Could not get total inventory count", err)
+	}
+	return q
+}
+
+// GetInventoryTotalVariety implements the Station interface
+func (s *remoteStation) GetInventoryTotalVariety() int {
+	var q int
+	if err := s.get("/inventory/variety", &q); err != nil {
+		logAllError("Could not get total inventory variety count", err)
+	}
+	return q
+}
+
+// send posts queued events to the headless abv in order.
+func (s *remoteStation) send() {
+	for e := range s.events {
+		body, err := json.Marshal(e)
+		if err != nil {
+			s.report("Could not encode event: ", err)
+			continue
+		}
+		req, err := http.NewRequest("POST", s.url+"/events", bytes.NewReader(body))
+		if err != nil {
+			s.report("Could not send event to headless abv: ", err)
+			continue
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+s.token)
+		resp, err := s.client.Do(req)
+		if err != nil {
+			s.report("Could not send event to headless abv: ", err)
+			continue
+		}
+		msg, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			s.report("Headless abv rejected event: ", resp.Status, ": ", strings.TrimSpace(string(msg)))
+		}
+	}
+}
+
+// stream receives the results of every event handled by the headless abv,
+// reconnecting whenever the connection is lost.
+func (s *remoteStation) stream() {
+	for {
+		err := s.readResults()
+		s.report("Lost connection to headless abv, reconnecting: ", err)
+		time.Sleep(reconnectDelay)
+	}
+}
+
+// readResults reads results from the headless abv until the stream ends.
+func (s *remoteStation) readResults() error {
+	resp, err := http.Get(s.url + "/results")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var r Result
+		if err := dec.Decode(&r); err != nil {
+			return err
+		}
+		if r.Error != "" {
+			r.Err = fmt.Errorf("%s", r.Error)
+		}
+		s.results <- r
+	}
+}
+
+// get decodes the JSON response to a GET request into val.
+func (s *remoteStation) get(path string, val interface{}) error {
+	resp, err := s.client.Get(s.url + path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("headless abv responded with %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(val)
+}
+
+// report delivers an error to the subscriber as the result of a failed event.
+func (s *remoteStation) report(args ...interface{}) {
+	r := Result{}
+	r.fail(args...)
+	select {
+	case s.results <- r:
+	default:
+	}
+}
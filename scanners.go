@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/bhutch29/abv/device"
+	"github.com/spf13/viper"
+)
+
+// Scanner is a named barcode scanner with its own operating Mode and
+// quantity of drinks per scan.
+//
+// Scanners are identified by the single character prefix "{c}_" they add to
+// each barcode. Input from unregistered IDs follows the global mode.
+//
+// A scanner with a Device is read directly rather than through the input
+// line of the user interface.
+type Scanner struct {
+	ID         string
+	Name       string
+	Mode       Mode
+	Quantity   int
+	Locked     bool
+	Device     string
+	DeviceType string
+	Baud       int
+}
+
+// String returns a human readable description of the scanner.
+func (s *Scanner) String() string {
+	if s.Name == "" {
+		return s.ID
+	}
+	return fmt.Sprintf("%s (%s)", s.Name, s.ID)
+}
+
+// loadScanners reads the [[scanners]] tables from the config file.
+func loadScanners(conf *viper.Viper) (map[string]*Scanner, error) {
+	var list []Scanner
+	if err := conf.UnmarshalKey("scanners", &list); err != nil {
+		return nil, err
+	}
+
+	scanners := make(map[string]*Scanner)
+	for i := range list {
+		s := list[i]
+		if len(s.ID) != 1 {
+			return nil, fmt.Errorf("scanner ID %q must be a single character", s.ID)
+		}
+		if _, exists := scanners[s.ID]; exists {
+			return nil, fmt.Errorf("scanner ID %q is defined more than once", s.ID)
+		}
+		if s.Mode == "" {
+			s.Mode = serving
+		}
+		if s.Mode != serving && s.Mode != stocking {
+			return nil, fmt.Errorf("scanner %s has unknown mode %q", s.String(), s.Mode)
+		}
+		if s.Quantity == 0 {
+			s.Quantity = 1
+		}
+		if s.Quantity < 0 {
+			return nil, fmt.Errorf("scanner %s has invalid quantity %d", s.String(), s.Quantity)
+		}
+		if max := conf.GetInt("maxServeQuantity"); s.Mode == serving && s.Quantity > max {
+			return nil, fmt.Errorf("scanner %s cannot serve more than %d drinks at once", s.String(), max)
+		}
+		if s.Device != "" && s.DeviceType == "" {
+			s.DeviceType = device.Evdev
+		}
+		scanners[s.ID] = &s
+	}
+	return scanners, nil
+}
+
+// listenDevices reads barcodes from every registered scanner with a device
+// and sends them to the controller, regardless of which view has focus.
+// Device errors are passed to onError.
+func listenDevices(ctrl *ModalController, onError func(error)) error {
+	var devices []device.Device
+	for _, s := range ctrl.Status().Scanners {
+		if s.Device != "" {
+			devices = append(devices, device.Device{ID: s.ID, Path: s.Device, Type: s.DeviceType, Baud: s.Baud})
+		}
+	}
+	if len(devices) == 0 {
+		return nil
+	}
+
+	scans := make(chan device.Scan)
+	errs := make(chan error)
+	if err := device.Listen(devices, scans, errs); err != nil {
+		return err
+	}
+
+	go func() {
+		for {
+			select {
+			case s := <-scans:
+				ctrl.Post(Event{Kind: scanEvent, ID: s.ID, Barcode: s.Barcode})
+			case err := <-errs:
+				onError(err)
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bhutch29/abv/model"
+	"github.com/julienschmidt/httprouter"
+)
+
+// Station is used by the user interface to drive a ModalController, either
+// in the same process or in a headless abv elsewhere on the network.
+type Station interface {
+	Post(e Event)
+	Status() Status
+	Subscribe() <-chan Result
+	GetInventorySorted(sortFields []string) []model.StockedDrink
+	GetInventoryTotalQuantity() int
+	GetInventoryTotalVariety() int
+}
+
+// stationStatus is the response to a station status request.
+type stationStatus struct {
+	Status
+	Version string
+	Started time.Time
+}
+
+// serveStation exposes a controller over HTTP, so attached user interfaces,
+// the API and network scan sources can all drive it.
+//
+// Events are submitted with POST /events, and the results of every event are
+// streamed as newline-delimited JSON from GET /results. Events need an API
+// token with the scope returned by scopeFor, checked against the backend.
+func serveStation(ctrl *ModalController, addr string) error {
+	started := time.Now()
+	router := httprouter.New()
+
+	router.GET("/status", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		writeJSON(w, stationStatus{ctrl.Status(), version, started})
+	})
+
+	router.POST("/events", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		secret := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if secret == "" || secret == r.Header.Get("Authorization") {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="abv"`)
+			http.Error(w, "missing API token", http.StatusUnauthorized)
+			return
+		}
+		t, err := ctrl.backend.Authenticate(secret)
+		if err == model.ErrInvalidToken {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="abv", error="invalid_token"`)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		var e Event
+		if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		e.allows = t.Allows
+		res := ctrl.Do(e)
+		if res.forbidden {
+			http.Error(w, res.Error, http.StatusForbidden)
+			return
+		}
+		writeJSON(w, res)
+	})
+
+	router.GET("/results", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		results := ctrl.Subscribe()
+		defer ctrl.Unsubscribe(results)
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		flusher, _ := w.(http.Flusher)
+		enc := json.NewEncoder(w)
+		for {
+			select {
+			case res := <-results:
+				if err := enc.Encode(res); err != nil {
+					return
+				}
+				if flusher != nil {
+					flusher.Flush()
+				}
+			case <-r.Context().Done():
+				return
+			}
+		}
+	})
+
+	router.GET("/inventory", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		q := model.InventoryQuery{}
+		if s := r.URL.Query().Get("sort"); s != "" {
+			q.Sort = strings.Split(s, ",")
+		}
+		if err := q.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, ctrl.GetInventorySorted(q.Sort))
+	})
+
+	router.GET("/inventory/quantity", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		writeJSON(w, ctrl.GetInventoryTotalQuantity())
+	})
+
+	router.GET("/inventory/variety", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		writeJSON(w, ctrl.GetInventoryTotalVariety())
+	})
+
+	return http.ListenAndServe(addr, router)
+}
+
+// scopeFor returns the API token scope needed to send an event to the
+// station. Serving needs the serve scope and anything which could change
+// stock or drinks needs the stock scope. Voids only take back servings the
+// scanner recorded, so they need the serve scope while serving. Scanned
+// command barcodes need the scope of their action.
+func (c *ModalController) scopeFor(e Event) string {
+	if cmd, ok := c.commands[e.Barcode]; ok && e.Kind == scanEvent {
+		cmd.ID = e.ID
+		return c.scopeFor(cmd)
+	}
+	servingOnly := c.modeFor(e.ID) == serving && c.counts[e.ID] == nil
+	switch e.Kind {
+	case modeEvent:
+		if e.Mode == serving {
+			return model.ScopeServe
+		}
+	case scanEvent, quantityEvent, undoEvent, redoEvent, startBatchEvent, endBatchEvent,
+		voidEvent, openTabEvent, closeTabEvent, statusEvent:
+		if servingOnly {
+			return model.ScopeServe
+		}
+	}
+	return model.ScopeStock
+}
+
+// writeJSON writes a value as a JSON response.
+func writeJSON(w http.ResponseWriter, val interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(val)
+}
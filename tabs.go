@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/bhutch29/abv/model"
+	"github.com/bhutch29/abv/undo"
+	"github.com/sirupsen/logrus"
+)
+
+// tab is a group of servings which are undone together, such as the drinks
+// of one table
+type tab struct {
+	number   int
+	servings map[*undo.OutputDrinksAction]bool
+}
+
+func (t *tab) String() string {
+	return fmt.Sprintf("tab %d", t.number)
+}
+
+// drinks returns the number of drinks served on the tab which were not voided
+func (t *tab) drinks() int {
+	n := 0
+	for s := range t.servings {
+		n += s.Entry().Quantity
+	}
+	return n
+}
+
+// openTab opens a new tab for the input device with the given ID, closing
+// its current tab if it has one. The tab is an undo batch, so undoing while
+// it is open reverts every drink on the tab.
+func (c *ModalController) openTab(r *Result, id string) {
+	if c.modeFor(id) != serving {
+		r.log(logrus.WarnLevel, "Tabs can only be opened in serving mode")
+		return
+	}
+	if c.tabs[id] != nil {
+		c.closeTab(r, id)
+	}
+
+	t := &tab{number: c.lastTab + 1, servings: make(map[*undo.OutputDrinksAction]bool)}
+	if !c.actor.StartBatch(id, t.String()) {
+		r.log(logrus.WarnLevel, "A batch is already in progress"+c.prettyID(id))
+		return
+	}
+	c.lastTab = t.number
+	c.tabs[id] = t
+	r.log(logrus.InfoLevel, "Opened ", t, c.prettyID(id), ". Drinks served until it is closed are undone together")
+}
+
+// closeTab closes the open tab of the input device with the given ID.
+func (c *ModalController) closeTab(r *Result, id string) {
+	t := c.tabs[id]
+	if t == nil {
+		r.log(logrus.WarnLevel, "No tab is open"+c.prettyID(id))
+		return
+	}
+	delete(c.tabs, id)
+	c.actor.EndBatch(id)
+	r.log(logrus.InfoLevel, "Closed ", t, " of ", t.drinks(), " drinks", c.prettyID(id))
+}
+
+// armVoid makes the next drink scanned by the input device with the given ID
+// be voided instead of stocked or served. Voiding again cancels it.
+func (c *ModalController) armVoid(r *Result, id string) {
+	if c.voids[id] {
+		delete(c.voids, id)
+		r.log(logrus.InfoLevel, "Cancelled void"+c.prettyID(id))
+		return
+	}
+	c.voids[id] = true
+	if c.counts[id] != nil {
+		r.log(logrus.InfoLevel, "Scan a drink to take it back out of the count")
+		return
+	}
+	r.log(logrus.InfoLevel, "Scan a drink to void its ", c.modeFor(id))
+}
+
+// voidDrink takes back the newest entry of a drink which the input device
+// with the given ID stocked or served, depending on its mode, and which can
+// still be undone. Only its own entries can be voided, so a void never adds
+// more stock than the device took out.
+func (c *ModalController) voidDrink(r *Result, id string, bc string) {
+	delete(c.voids, id)
+
+	drink, err := c.backend.GetDrinkByBarcode(bc)
+	if err != nil {
+		r.log(logrus.ErrorLevel, "Could not get Drink information from barcode: ", err)
+	}
+
+	mode := c.modeFor(id)
+	found := c.actor.Find(id, func(a undo.ReversibleAction) bool {
+		switch a := a.(type) {
+		case *undo.InputDrinksAction:
+			return mode == stocking && a.Entry().Barcode == bc
+		case *undo.OutputDrinksAction:
+			return mode == serving && a.Entry().Barcode == bc
+		}
+		return false
+	})
+	if found == nil {
+		r.log(logrus.WarnLevel, "No ", mode, " of that drink to void", c.prettyID(id))
+		return
+	}
+
+	var de model.DrinkEntry
+	switch a := found.(type) {
+	case *undo.InputDrinksAction:
+		de = a.Entry()
+		count, err := c.backend.GetCountByBarcode(bc)
+		if err != nil {
+			r.fail("Could not get count by barcode: ", err)
+			return
+		}
+		if count < de.Quantity {
+			r.log(logrus.WarnLevel, "Only ", count, " of that drink left in the inventory! Nothing was voided")
+			return
+		}
+	case *undo.OutputDrinksAction:
+		de = a.Entry()
+	}
+
+	label := describeEntry("void of "+string(mode), de, drink)
+	if err := c.actor.AddAction(id, undo.WithLabel(label, undo.NewVoidAction(found))); err != nil {
+		r.fail("Could not void drink: ", err)
+		return
+	}
+	r.Changed = true
+	if o, ok := found.(*undo.OutputDrinksAction); ok && c.tabs[id] != nil {
+		delete(c.tabs[id].servings, o)
+	}
+	r.log(logrus.InfoLevel, "Drink voided!\n  #:     ", de.Quantity, "\n  Name:  ", drink.Name, "\n  Brand: ", drink.Brand, c.scannerLine(id))
+}
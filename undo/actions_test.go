@@ -0,0 +1,258 @@
+package undo
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bhutch29/abv/model"
+	"github.com/spf13/viper"
+)
+
+func newTestModel(t *testing.T) *model.Model {
+	m, err := model.Open(":memory:", viper.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { m.Close() })
+	return &m
+}
+
+func checkCount(m *model.Model, barcode string, want int, t *testing.T) {
+	t.Helper()
+	count, err := m.GetCountByBarcode(barcode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != want {
+		t.Errorf("count of %s = %d, want %d", barcode, count, want)
+	}
+}
+
+func checkExists(m *model.Model, barcode string, want bool, t *testing.T) {
+	t.Helper()
+	exists, err := m.BarcodeExists(barcode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists != want {
+		t.Errorf("drink %s exists = %v, want %v", barcode, exists, want)
+	}
+}
+
+func TestCreateDrinkAction(t *testing.T) {
+	m := newTestModel(t)
+	a := NewCreateDrinkAction(m, model.Drink{Barcode: "123", Name: "Test"})
+	if err := a.Do(); err != nil {
+		t.Fatal(err)
+	}
+	checkExists(m, "123", true, t)
+	if err := a.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	checkExists(m, "123", false, t)
+}
+
+func TestInputOutputDrinksActions(t *testing.T) {
+	m := newTestModel(t)
+	in := NewInputDrinksAction(m, model.DrinkEntry{Barcode: "123", Quantity: 6})
+	out := NewOutputDrinksAction(m, model.DrinkEntry{Barcode: "123", Quantity: 2})
+
+	if err := in.Do(); err != nil {
+		t.Fatal(err)
+	}
+	checkCount(m, "123", 6, t)
+	if err := out.Do(); err != nil {
+		t.Fatal(err)
+	}
+	checkCount(m, "123", 4, t)
+	if err := out.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	checkCount(m, "123", 6, t)
+	if err := in.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	checkCount(m, "123", 0, t)
+}
+
+func TestCorrectDrinksAction(t *testing.T) {
+	m := newTestModel(t)
+	m.InputDrinks(model.DrinkEntry{Barcode: "123", Quantity: 6})
+	correction := NewCorrectDrinksAction(m, model.DrinkEntry{Barcode: "123", Quantity: -2})
+
+	if err := correction.Do(); err != nil {
+		t.Fatal(err)
+	}
+	checkCount(m, "123", 4, t)
+	if err := correction.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	checkCount(m, "123", 6, t)
+}
+
+func TestCreateAndInputAction(t *testing.T) {
+	m := newTestModel(t)
+	a := NewCreateAndInputAction(m, model.Drink{Barcode: "123"}, model.DrinkEntry{Barcode: "123", Quantity: 1})
+	if err := a.Do(); err != nil {
+		t.Fatal(err)
+	}
+	checkExists(m, "123", true, t)
+	checkCount(m, "123", 1, t)
+	if err := a.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	checkExists(m, "123", false, t)
+	checkCount(m, "123", 0, t)
+}
+
+func TestActorWithModel(t *testing.T) {
+	m := newTestModel(t)
+	a := NewActor()
+	a.AddAction("1", NewInputDrinksAction(m, model.DrinkEntry{Barcode: "123", Quantity: 2}))
+	a.AddAction("1", NewOutputDrinksAction(m, model.DrinkEntry{Barcode: "123", Quantity: 1}))
+	a.Undo("1")
+	checkCount(m, "123", 2, t)
+	a.Undo("1")
+	checkCount(m, "123", 0, t)
+	a.Redo("1")
+	checkCount(m, "123", 2, t)
+}
+
+// failingTx fails to input drinks after any other changes have been made
+type failingTx struct {
+	*model.Tx
+}
+
+func (t failingTx) InputDrinks(d model.DrinkEntry) (int, error) {
+	return -1, errors.New("input failed")
+}
+
+func TestCreateAndInputActionRollsBack(t *testing.T) {
+	m := newTestModel(t)
+	a := NewTxActor(func() (Tx, error) {
+		tx, err := m.Begin()
+		if err != nil {
+			return nil, err
+		}
+		return failingTx{tx}, nil
+	})
+	err := a.AddAction("", NewCreateAndInputAction(m, model.Drink{Barcode: "123"}, model.DrinkEntry{Barcode: "123", Quantity: 1}))
+	if err == nil {
+		t.Fatal("expected the action to fail")
+	}
+	checkExists(m, "123", false, t)
+	checkCount(m, "123", 0, t)
+}
+
+func TestTxActorCommits(t *testing.T) {
+	m := newTestModel(t)
+	a := NewTxActor(func() (Tx, error) {
+		tx, err := m.Begin()
+		if err != nil {
+			return nil, err
+		}
+		return tx, nil
+	})
+	a.AddAction("", NewCreateAndInputAction(m, model.Drink{Barcode: "123"}, model.DrinkEntry{Barcode: "123", Quantity: 3}))
+	checkExists(m, "123", true, t)
+	checkCount(m, "123", 3, t)
+	a.Undo("")
+	checkExists(m, "123", false, t)
+	checkCount(m, "123", 0, t)
+}
+
+// undoFailingTx fails to undo the serving with the given id
+type undoFailingTx struct {
+	*model.Tx
+	id *int
+}
+
+func (t undoFailingTx) UndoOutputDrinks(id int) error {
+	if id == *t.id {
+		return errors.New("undo failed")
+	}
+	return t.Tx.UndoOutputDrinks(id)
+}
+
+func TestBatchFailingInTxIsRolledBack(t *testing.T) {
+	m := newTestModel(t)
+	failID := 1
+	a := NewTxActor(func() (Tx, error) {
+		tx, err := m.Begin()
+		if err != nil {
+			return nil, err
+		}
+		return undoFailingTx{tx, &failID}, nil
+	})
+	m.InputDrinks(model.DrinkEntry{Barcode: "123", Quantity: 10})
+	a.StartBatch("", "test")
+	a.AddAction("", NewOutputDrinksAction(m, model.DrinkEntry{Barcode: "123", Quantity: 1}))
+	a.AddAction("", NewOutputDrinksAction(m, model.DrinkEntry{Barcode: "123", Quantity: 2}))
+	a.EndBatch("")
+
+	m.OutputDrinks(model.DrinkEntry{Barcode: "123", Quantity: 3})
+
+	if _, err := a.Undo(""); err == nil {
+		t.Fatal("expected the undo to fail")
+	}
+	checkCount(m, "123", 4, t)
+
+	// Another serving takes the next id, which the rolled back transaction
+	// had also used
+	m.OutputDrinks(model.DrinkEntry{Barcode: "123", Quantity: 4})
+	failID = 0
+	if _, err := a.Undo(""); err != nil {
+		t.Fatal(err)
+	}
+	checkCount(m, "123", 3, t)
+}
+
+// servingOf matches servings of the given barcode
+func servingOf(bc string) func(ReversibleAction) bool {
+	return func(a ReversibleAction) bool {
+		o, ok := a.(*OutputDrinksAction)
+		return ok && o.Entry().Barcode == bc
+	}
+}
+
+func TestVoidAction(t *testing.T) {
+	m := newTestModel(t)
+	a := NewTxActor(func() (Tx, error) {
+		tx, err := m.Begin()
+		if err != nil {
+			return nil, err
+		}
+		return tx, nil
+	})
+	m.InputDrinks(model.DrinkEntry{Barcode: "123", Quantity: 10})
+	a.AddAction("1", WithLabel("serving", NewOutputDrinksAction(m, model.DrinkEntry{Barcode: "123", Quantity: 1})))
+	a.StartBatch("1", "tab")
+	a.AddAction("1", NewOutputDrinksAction(m, model.DrinkEntry{Barcode: "123", Quantity: 2}))
+	a.AddAction("1", NewOutputDrinksAction(m, model.DrinkEntry{Barcode: "456", Quantity: 1}))
+	a.AddAction("2", NewOutputDrinksAction(m, model.DrinkEntry{Barcode: "123", Quantity: 3}))
+	checkCount(m, "123", 4, t)
+
+	for _, want := range []int{2, 1} {
+		found := a.Find("1", servingOf("123"))
+		if found == nil || found.(*OutputDrinksAction).Entry().Quantity != want {
+			t.Fatalf("found %+v, want the serving of %d", found, want)
+		}
+		if err := a.AddAction("1", NewVoidAction(found)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkCount(m, "123", 7, t)
+	if found := a.Find("1", servingOf("123")); found != nil {
+		t.Errorf("found %+v after every serving was voided", found)
+	}
+
+	// Undoing the tab undoes the void of the serving before it as well
+	a.EndBatch("1")
+	a.Undo("1")
+	checkCount(m, "123", 6, t)
+	if found := a.Find("1", servingOf("123")); found == nil || found.(*OutputDrinksAction).Entry().Quantity != 1 {
+		t.Errorf("found %+v after the tab was undone, want the serving of 1", found)
+	}
+	a.Redo("1")
+	checkCount(m, "123", 7, t)
+}
@@ -0,0 +1,165 @@
+package undo
+
+import (
+	"fmt"
+)
+
+// Batch groups any number of actions into a single ReversibleAction. The
+// actions are done in order and undone in reverse order.
+//
+// If one of the actions fails, the ones before it are reverted so the batch
+// is either applied or not at all. A BatchError with Partial set is returned
+// if that is not possible. Inside a transaction the actions are not
+// reverted, since the transaction is rolled back instead.
+type Batch struct {
+	label   string
+	actions []ReversibleAction
+}
+
+// NewBatch returns a Batch of the given actions
+func NewBatch(label string, actions ...ReversibleAction) *Batch {
+	b := Batch{label: label}
+	b.actions = append(b.actions, actions...)
+	return &b
+}
+
+// Add appends an action to the batch without doing it
+func (b *Batch) Add(a ReversibleAction) {
+	b.actions = append(b.actions, a)
+}
+
+// Len returns the number of actions in the batch
+func (b *Batch) Len() int {
+	return len(b.actions)
+}
+
+// Label implements the Labeled interface
+func (b *Batch) Label() string {
+	return b.label
+}
+
+// Do implements the ReversibleAction interface
+func (b *Batch) Do() error {
+	return b.do(doAction, undoAction)
+}
+
+// Undo implements the ReversibleAction interface
+func (b *Batch) Undo() error {
+	return b.undo(doAction, undoAction)
+}
+
+// DoWith implements the TxAction interface. Actions which are not TxActions
+// are done without the Repository.
+//
+// DoWith and UndoWith are run inside a transaction, which is rolled back if
+// an action fails. Reverting the other actions as well would give them the
+// ids of new entries which are rolled back, and which SQLite may later reuse
+// for unrelated entries.
+func (b *Batch) DoWith(r Repository) error {
+	return b.do(doActionWith(r), nil)
+}
+
+// UndoWith implements the TxAction interface
+func (b *Batch) UndoWith(r Repository) error {
+	return b.undo(nil, undoActionWith(r))
+}
+
+// do does the actions in order. If one fails, the actions before it are
+// reverted with undo, unless undo is nil.
+func (b *Batch) do(do, undo func(ReversibleAction) error) error {
+	for i, a := range b.actions {
+		if err := do(a); err != nil {
+			e := &BatchError{Label: b.label, Index: i, Err: err}
+			for j := i - 1; j >= 0 && undo != nil; j-- {
+				if err := undo(b.actions[j]); err != nil {
+					e.Partial = true
+					e.RevertErr = err
+					break
+				}
+			}
+			return e
+		}
+	}
+	return nil
+}
+
+// undo undoes the actions in reverse order. If one fails, the actions after
+// it are done again with do, unless do is nil.
+func (b *Batch) undo(do, undo func(ReversibleAction) error) error {
+	for i := len(b.actions) - 1; i >= 0; i-- {
+		if err := undo(b.actions[i]); err != nil {
+			e := &BatchError{Label: b.label, Index: i, Undo: true, Err: err}
+			for j := i + 1; j < len(b.actions) && do != nil; j++ {
+				if err := do(b.actions[j]); err != nil {
+					e.Partial = true
+					e.RevertErr = err
+					break
+				}
+			}
+			return e
+		}
+	}
+	return nil
+}
+
+func doAction(a ReversibleAction) error {
+	return a.Do()
+}
+
+func undoAction(a ReversibleAction) error {
+	return a.Undo()
+}
+
+func doActionWith(r Repository) func(ReversibleAction) error {
+	return func(a ReversibleAction) error {
+		if t, ok := a.(TxAction); ok {
+			return t.DoWith(r)
+		}
+		return a.Do()
+	}
+}
+
+func undoActionWith(r Repository) func(ReversibleAction) error {
+	return func(a ReversibleAction) error {
+		if t, ok := a.(TxAction); ok {
+			return t.UndoWith(r)
+		}
+		return a.Undo()
+	}
+}
+
+// BatchError reports the action of a Batch which failed. Partial is set if
+// the actions already done or undone could not be reverted, leaving the
+// batch partially applied.
+type BatchError struct {
+	Label     string
+	Index     int
+	Undo      bool
+	Err       error
+	Partial   bool
+	RevertErr error
+}
+
+func (e *BatchError) Error() string {
+	verb := "do"
+	if e.Undo {
+		verb = "undo"
+	}
+	msg := fmt.Sprintf("could not %s step %d of %s: %v", verb, e.Index+1, e.name(), e.Err)
+	if e.Partial {
+		msg += fmt.Sprintf(" (%s is partially applied: %v)", e.name(), e.RevertErr)
+	}
+	return msg
+}
+
+// Unwrap returns the error of the failed action
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+func (e *BatchError) name() string {
+	if e.Label == "" {
+		return "batch"
+	}
+	return e.Label
+}
@@ -0,0 +1,51 @@
+package undo
+
+import (
+	"github.com/bhutch29/abv/model"
+)
+
+// CorrectDrinksAction encapsulates correcting the stock of a drink after a count
+type CorrectDrinksAction struct {
+	id int
+	de model.DrinkEntry
+	m  Repository
+}
+
+// NewCorrectDrinksAction returns an initialized CorrectDrinksAction
+func NewCorrectDrinksAction(m Repository, de model.DrinkEntry) *CorrectDrinksAction {
+	c := CorrectDrinksAction{}
+	c.m = m
+	c.de = de
+	return &c
+}
+
+// Entry returns the drink entry recorded by the action
+func (a *CorrectDrinksAction) Entry() model.DrinkEntry {
+	return a.de
+}
+
+// Do implements the ReversibleAction interface
+func (a *CorrectDrinksAction) Do() error {
+	return a.DoWith(a.m)
+}
+
+// Undo implements the ReversibleAction interface
+func (a *CorrectDrinksAction) Undo() error {
+	return a.UndoWith(a.m)
+}
+
+// DoWith implements the TxAction interface
+func (a *CorrectDrinksAction) DoWith(r Repository) error {
+	i, err := r.CorrectDrinks(a.de)
+	if err != nil {
+		return err
+	}
+	a.id = i
+	return nil
+}
+
+// UndoWith implements the TxAction interface
+func (a *CorrectDrinksAction) UndoWith(r Repository) error {
+	err := r.UndoCorrectDrinks(a.id)
+	return err
+}
@@ -7,26 +7,35 @@ import (
 // CreateDrinkAction encapsulates adding a new drink to the database
 type CreateDrinkAction struct {
 	d model.Drink
-	m model.Model
+	m Repository
 }
 
 // NewCreateDrinkAction returns an initialized CreateDrinkAction
-func NewCreateDrinkAction(d model.Drink) *CreateDrinkAction {
+func NewCreateDrinkAction(m Repository, d model.Drink) *CreateDrinkAction {
 	c := CreateDrinkAction{}
-	mod, _ := model.New()
-	c.m = mod
+	c.m = m
 	c.d = d
 	return &c
 }
 
 // Do implements the ReversibleAction interface
 func (a *CreateDrinkAction) Do() error {
-	_, err := a.m.CreateDrink(a.d)
-	return err
+	return a.DoWith(a.m)
 }
 
 // Undo implements the ReversibleAction interface
 func (a *CreateDrinkAction) Undo() error {
-	err := a.m.DeleteDrink(a.d.Barcode)
+	return a.UndoWith(a.m)
+}
+
+// DoWith implements the TxAction interface
+func (a *CreateDrinkAction) DoWith(r Repository) error {
+	_, err := r.CreateDrink(a.d)
+	return err
+}
+
+// UndoWith implements the TxAction interface
+func (a *CreateDrinkAction) UndoWith(r Repository) error {
+	err := r.DeleteDrink(a.d.Barcode)
 	return err
 }
@@ -1,43 +1,197 @@
 package undo
 
+import (
+	"sort"
+	"time"
+)
+
 // Actor encapsulates all undo/redo functionality. Use NewActor() to create an initialized Actor
 type Actor struct {
-	lists map[string]*undoList
+	lists   map[string]*undoList
+	batches map[string]*Batch
+	begin   BeginFunc
+	limits  Limits
+	now     func() time.Time
 }
 
 // NewActor creates an initialized Actor
 func NewActor() Actor {
 	l := make(map[string]*undoList)
-	h := Actor{l}
+	h := Actor{lists: l, batches: make(map[string]*Batch), now: time.Now}
+	return h
+}
+
+// NewTxActor creates an initialized Actor which runs every TxAction inside a
+// transaction started by begin, so that its steps are committed or rolled
+// back together
+func NewTxActor(begin BeginFunc) Actor {
+	h := NewActor()
+	h.begin = begin
 	return h
 }
 
 // AddAction performs the action and appends it onto the current node and updates the current node. Will destroy any history ahead of the current node.
+//
+// While a batch is open for the id, the action is added to the batch instead.
 func (h *Actor) AddAction(id string, a ReversibleAction) error {
+	if b, open := h.batches[id]; open {
+		if err := h.wrap(a).Do(); err != nil {
+			return err
+		}
+		b.Add(a)
+		return nil
+	}
 	l := h.getList(id)
-	err := l.addAction(a)
-	return err
+	if err := l.addAction(h.wrap(a), h.now()); err != nil {
+		return err
+	}
+	h.prune(id)
+	return nil
+}
+
+// StartBatch groups the following actions with the given id into a single
+// Batch with the given label, so they can be undone together. Returns false
+// if a batch is already open for the id.
+func (h *Actor) StartBatch(id string, label string) bool {
+	if _, open := h.batches[id]; open {
+		return false
+	}
+	h.batches[id] = NewBatch(label)
+	return true
+}
+
+// EndBatch closes the open batch with the given id and appends it onto the current node. Returns the batch, or nil if no batch was open.
+func (h *Actor) EndBatch(id string) *Batch {
+	b, open := h.batches[id]
+	if !open {
+		return nil
+	}
+	delete(h.batches, id)
+	if b.Len() > 0 {
+		h.getList(id).push(h.wrap(b), h.now())
+		h.prune(id)
+	}
+	return b
 }
 
 // Undo reverses out the current action and moves the current pointer back one action. If current action is the head, do nothing
+//
+// An open batch with the id is ended first, so all of its actions are reversed.
 func (h *Actor) Undo(id string) (bool, error) {
-	l := h.getList(id)
-	acted, err := l.undo()
+	h.EndBatch(id)
+	l := h.prune(id)
+	if l == nil {
+		return false, nil
+	}
+	acted, err := l.undo(h.now())
 	return acted, err
 }
 
 // Redo moves the current pointer ahead one action and performs it. If current action is the tail, do nothing
 func (h *Actor) Redo(id string) (bool, error) {
-	l := h.getList(id)
-	acted, err := l.redo()
+	h.EndBatch(id)
+	l := h.prune(id)
+	if l == nil {
+		return false, nil
+	}
+	acted, err := l.redo(h.now())
 	return acted, err
 }
 
+// NextUndo returns the action the next Undo with the given id would reverse, or nil
+func (h *Actor) NextUndo(id string) ReversibleAction {
+	if b, open := h.batches[id]; open && b.Len() > 0 {
+		return b
+	}
+	l := h.prune(id)
+	if l == nil || isHead(l.current) {
+		return nil
+	}
+	return l.current.action
+}
+
+// NextRedo returns the action the next Redo with the given id would perform, or nil
+func (h *Actor) NextRedo(id string) ReversibleAction {
+	if b, open := h.batches[id]; open && b.Len() > 0 {
+		return nil
+	}
+	l := h.prune(id)
+	if l == nil || l.current.next == nil {
+		return nil
+	}
+	return l.current.next.action
+}
+
+// Find returns the newest action with the given id which can still be
+// undone and for which match returns true, or nil. Actions inside batches,
+// including an open batch, are searched as well. Actions which have been
+// taken back by a VoidAction are skipped.
+func (h *Actor) Find(id string, match func(ReversibleAction) bool) ReversibleAction {
+	var done []ReversibleAction
+	if b, open := h.batches[id]; open {
+		done = append(done, b)
+	}
+	if l := h.prune(id); l != nil {
+		for n := l.current; !isHead(n); n = n.previous {
+			done = append(done, n.action)
+		}
+	}
+	voided := make(map[ReversibleAction]bool)
+	for _, a := range done {
+		if found := find(a, match, voided); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// InBatch returns whether a batch is open for the given id
+func (h *Actor) InBatch(id string) bool {
+	_, open := h.batches[id]
+	return open
+}
+
+// History returns the actions with the given id, oldest first. Actions
+// after the current one have been undone and can be redone. Actions in an
+// open batch are not included until the batch is ended.
+func (h *Actor) History(id string) []HistoryEntry {
+	l := h.prune(id)
+	if l == nil {
+		return nil
+	}
+	return l.history()
+}
+
+// IDs returns the sorted ids which have any undo history or an open batch
+func (h *Actor) IDs() []string {
+	h.collect()
+	var ids []string
+	for id := range h.lists {
+		ids = append(ids, id)
+	}
+	for id := range h.batches {
+		if _, exists := h.lists[id]; !exists {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// wrap runs TxActions inside a transaction if the Actor has a BeginFunc
+func (h *Actor) wrap(a ReversibleAction) ReversibleAction {
+	if t, ok := a.(TxAction); ok && h.begin != nil {
+		return &txAction{t, h.begin}
+	}
+	return a
+}
+
 func (h *Actor) getList(id string) *undoList {
+	h.collect()
 	if list, exists := h.lists[id]; exists {
 		return list
 	}
-	l := newUndoList()
+	l := newUndoList(h.now())
 	h.lists[id] = &l
 	return &l
 }
@@ -8,21 +8,35 @@ import (
 type InputDrinksAction struct {
 	id int
 	de model.DrinkEntry
-	m  model.Model
+	m  Repository
 }
 
 // NewInputDrinksAction returns an initialized InputDrinksAction
-func NewInputDrinksAction(de model.DrinkEntry) *InputDrinksAction {
+func NewInputDrinksAction(m Repository, de model.DrinkEntry) *InputDrinksAction {
 	i := InputDrinksAction{}
-	mod, _ := model.New()
-	i.m = mod
+	i.m = m
 	i.de = de
 	return &i
 }
 
+// Entry returns the drink entry recorded by the action
+func (a *InputDrinksAction) Entry() model.DrinkEntry {
+	return a.de
+}
+
 // Do implements the ReversibleAction interface
 func (a *InputDrinksAction) Do() error {
-	i, err := a.m.InputDrinks(a.de)
+	return a.DoWith(a.m)
+}
+
+// Undo implements the ReversibleAction interface
+func (a *InputDrinksAction) Undo() error {
+	return a.UndoWith(a.m)
+}
+
+// DoWith implements the TxAction interface
+func (a *InputDrinksAction) DoWith(r Repository) error {
+	i, err := r.InputDrinks(a.de)
 	if err != nil {
 		return err
 	}
@@ -30,8 +44,8 @@ func (a *InputDrinksAction) Do() error {
 	return nil
 }
 
-// Undo implements the ReversibleAction interface
-func (a *InputDrinksAction) Undo() error {
-	err := a.m.UndoInputDrinks(a.id)
+// UndoWith implements the TxAction interface
+func (a *InputDrinksAction) UndoWith(r Repository) error {
+	err := r.UndoInputDrinks(a.id)
 	return err
 }
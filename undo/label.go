@@ -0,0 +1,69 @@
+package undo
+
+import (
+	"time"
+)
+
+// Labeled is implemented by actions with a human readable description
+type Labeled interface {
+	Label() string
+}
+
+// HistoryEntry describes an action in the undo history of an id
+type HistoryEntry struct {
+	Label  string
+	Time   time.Time // when the action was last done
+	Undone bool      // the action has been undone and can be redone
+}
+
+// Describe returns the label of the action if it has one
+func Describe(a ReversibleAction) string {
+	if l := labelOf(a); l != "" {
+		return l
+	}
+	return "last action"
+}
+
+func labelOf(a ReversibleAction) string {
+	if l, ok := a.(Labeled); ok {
+		return l.Label()
+	}
+	return ""
+}
+
+// WithLabel returns the action with the given label
+func WithLabel(label string, a ReversibleAction) ReversibleAction {
+	return &labeledAction{a, label}
+}
+
+// labeledAction adds a label to another action. It is a TxAction even if
+// the action is not, in which case the Repository is not used.
+type labeledAction struct {
+	action ReversibleAction
+	label  string
+}
+
+// Label implements the Labeled interface
+func (a *labeledAction) Label() string {
+	return a.label
+}
+
+// Do implements the ReversibleAction interface
+func (a *labeledAction) Do() error {
+	return a.action.Do()
+}
+
+// Undo implements the ReversibleAction interface
+func (a *labeledAction) Undo() error {
+	return a.action.Undo()
+}
+
+// DoWith implements the TxAction interface
+func (a *labeledAction) DoWith(r Repository) error {
+	return doActionWith(r)(a.action)
+}
+
+// UndoWith implements the TxAction interface
+func (a *labeledAction) UndoWith(r Repository) error {
+	return undoActionWith(r)(a.action)
+}
@@ -0,0 +1,54 @@
+package undo
+
+import (
+	"time"
+)
+
+// Limits bounds the undo history kept by an Actor. Zero values mean no limit.
+type Limits struct {
+	// Depth is the most actions of an id which can be undone
+	Depth int
+	// MaxAge is how long after an action was done it can still be undone
+	MaxAge time.Duration
+	// IdleTimeout is how long the history of an id is kept after its last
+	// action, undo or redo
+	IdleTimeout time.Duration
+}
+
+// SetLimits bounds the undo history kept from now on
+func (h *Actor) SetLimits(l Limits) {
+	h.limits = l
+	for id := range h.lists {
+		h.prune(id)
+	}
+}
+
+// prune applies the depth and age limits to the list with the given id, and
+// discards the list if it is idle or empty. Returns the list, or nil if it
+// does not exist.
+func (h *Actor) prune(id string) *undoList {
+	l, exists := h.lists[id]
+	if !exists {
+		return nil
+	}
+	now := h.now()
+	if h.limits.Depth > 0 {
+		l.trim(h.limits.Depth)
+	}
+	if h.limits.MaxAge > 0 {
+		l.expire(now.Add(-h.limits.MaxAge))
+	}
+	idle := h.limits.IdleTimeout > 0 && now.Sub(l.lastUsed) > h.limits.IdleTimeout
+	if (idle || l.empty()) && !h.InBatch(id) {
+		delete(h.lists, id)
+		return nil
+	}
+	return l
+}
+
+// collect prunes every list, discarding the idle ones
+func (h *Actor) collect() {
+	for id := range h.lists {
+		h.prune(id)
+	}
+}
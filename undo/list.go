@@ -1,32 +1,44 @@
 package undo
 
+import (
+	"time"
+)
+
 type undoList struct {
-	current *node
+	head, current *node
+	lastUsed      time.Time
 }
 
-func newUndoList() undoList {
+func newUndoList(now time.Time) undoList {
 	head := node{}
-	h := undoList{&head}
+	h := undoList{head: &head, current: &head, lastUsed: now}
 	return h
 }
 
 type node struct {
 	action         ReversibleAction
+	time           time.Time
 	next, previous *node
 }
 
-func (l *undoList) addAction(a ReversibleAction) error {
+func (l *undoList) addAction(a ReversibleAction, now time.Time) error {
 	err := a.Do()
 	if err != nil {
 		return err
 	}
-	n := node{action: a, previous: l.current}
+	l.push(a, now)
+	return nil
+}
+
+// push appends an action which has already been done
+func (l *undoList) push(a ReversibleAction, now time.Time) {
+	n := node{action: a, time: now, previous: l.current}
 	l.current.next = &n
 	l.current = &n
-	return nil
+	l.lastUsed = now
 }
 
-func (l *undoList) undo() (bool, error) {
+func (l *undoList) undo(now time.Time) (bool, error) {
 	if isHead(l.current) {
 		return false, nil
 	}
@@ -34,10 +46,11 @@ func (l *undoList) undo() (bool, error) {
 		return false, err
 	}
 	l.current = l.current.previous
+	l.lastUsed = now
 	return true, nil
 }
 
-func (l *undoList) redo() (bool, error) {
+func (l *undoList) redo(now time.Time) (bool, error) {
 	if l.current.next == nil {
 		return false, nil
 	}
@@ -45,9 +58,60 @@ func (l *undoList) redo() (bool, error) {
 	if err := l.current.action.Do(); err != nil {
 		return false, err
 	}
+	l.current.time = now
+	l.lastUsed = now
 	return true, nil
 }
 
+// trim discards the oldest actions so that at most depth can be undone
+func (l *undoList) trim(depth int) {
+	n := l.current
+	for i := 0; i < depth && !isHead(n); i++ {
+		n = n.previous
+	}
+	if isHead(n) {
+		return
+	}
+	l.head.next = n.next
+	n.next.previous = l.head
+}
+
+// expire discards the actions done before cutoff which could be undone.
+// Actions which can be redone are kept.
+func (l *undoList) expire(cutoff time.Time) {
+	for !isHead(l.current) {
+		first := l.head.next
+		if !first.time.Before(cutoff) {
+			return
+		}
+		l.head.next = first.next
+		if first.next != nil {
+			first.next.previous = l.head
+		}
+		if first == l.current {
+			l.current = l.head
+		}
+	}
+}
+
+// empty returns whether there is nothing to undo or redo
+func (l *undoList) empty() bool {
+	return l.head.next == nil
+}
+
 func isHead(n *node) bool {
 	return n.action == nil || n.previous == nil
 }
+
+// history returns an entry for every action in the list, oldest first
+func (l *undoList) history() []HistoryEntry {
+	var entries []HistoryEntry
+	undone := isHead(l.current)
+	for n := l.head.next; n != nil; n = n.next {
+		entries = append(entries, HistoryEntry{Label: labelOf(n.action), Time: n.time, Undone: undone})
+		if n == l.current {
+			undone = true
+		}
+	}
+	return entries
+}
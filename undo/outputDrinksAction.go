@@ -8,21 +8,35 @@ import (
 type OutputDrinksAction struct {
 	id int
 	de model.DrinkEntry
-	m  model.Model
+	m  Repository
 }
 
 // NewOutputDrinksAction returns an initialized OutputDrinksAction
-func NewOutputDrinksAction(de model.DrinkEntry) *OutputDrinksAction {
+func NewOutputDrinksAction(m Repository, de model.DrinkEntry) *OutputDrinksAction {
 	o := OutputDrinksAction{}
-	mod, _ := model.New()
-	o.m = mod
+	o.m = m
 	o.de = de
 	return &o
 }
 
+// Entry returns the drink entry recorded by the action
+func (a *OutputDrinksAction) Entry() model.DrinkEntry {
+	return a.de
+}
+
 // Do implements the ReversibleAction interface
 func (a *OutputDrinksAction) Do() error {
-	i, err := a.m.OutputDrinks(a.de)
+	return a.DoWith(a.m)
+}
+
+// Undo implements the ReversibleAction interface
+func (a *OutputDrinksAction) Undo() error {
+	return a.UndoWith(a.m)
+}
+
+// DoWith implements the TxAction interface
+func (a *OutputDrinksAction) DoWith(r Repository) error {
+	i, err := r.OutputDrinks(a.de)
 	if err != nil {
 		return err
 	}
@@ -30,8 +44,8 @@ func (a *OutputDrinksAction) Do() error {
 	return nil
 }
 
-// Undo implements the ReversibleAction interface
-func (a *OutputDrinksAction) Undo() error {
-	err := a.m.UndoOutputDrinks(a.id)
+// UndoWith implements the TxAction interface
+func (a *OutputDrinksAction) UndoWith(r Repository) error {
+	err := r.UndoOutputDrinks(a.id)
 	return err
 }
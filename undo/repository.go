@@ -0,0 +1,18 @@
+package undo
+
+import (
+	"github.com/bhutch29/abv/model"
+)
+
+// Repository is the storage the built-in actions make their changes to.
+// model.Model and model.Client both implement it.
+type Repository interface {
+	CreateDrink(d model.Drink) (int, error)
+	DeleteDrink(bc string) error
+	InputDrinks(d model.DrinkEntry) (int, error)
+	UndoInputDrinks(id int) error
+	OutputDrinks(d model.DrinkEntry) (int, error)
+	UndoOutputDrinks(id int) error
+	CorrectDrinks(d model.DrinkEntry) (int, error)
+	UndoCorrectDrinks(id int) error
+}
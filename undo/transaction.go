@@ -0,0 +1,52 @@
+package undo
+
+// Tx is a Repository whose changes are committed or rolled back as a unit
+type Tx interface {
+	Repository
+	Commit() error
+	Rollback() error
+}
+
+// BeginFunc starts a transaction for an Actor to run actions in
+type BeginFunc func() (Tx, error)
+
+// TxAction is a ReversibleAction that can make its changes through a
+// Repository supplied by the Actor, such as a Tx.
+type TxAction interface {
+	ReversibleAction
+	DoWith(r Repository) error
+	UndoWith(r Repository) error
+}
+
+// txAction runs a TxAction inside a new transaction each time it is done or undone
+type txAction struct {
+	action TxAction
+	begin  BeginFunc
+}
+
+// Do implements the ReversibleAction interface
+func (a *txAction) Do() error {
+	return a.run(a.action.DoWith)
+}
+
+// Undo implements the ReversibleAction interface
+func (a *txAction) Undo() error {
+	return a.run(a.action.UndoWith)
+}
+
+// Label implements the Labeled interface
+func (a *txAction) Label() string {
+	return labelOf(a.action)
+}
+
+func (a *txAction) run(f func(Repository) error) error {
+	tx, err := a.begin()
+	if err != nil {
+		return err
+	}
+	if err := f(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
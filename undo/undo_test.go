@@ -1,8 +1,10 @@
 package undo
 
 import (
+	"errors"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestAddAction(t *testing.T) {
@@ -59,3 +61,184 @@ func (a *dummyAction) Undo() error {
 	a.Calls = append(a.Calls, "Undo")
 	return nil
 }
+
+func TestBatchUndoesInReverseOrder(t *testing.T) {
+	var calls []string
+	b := NewBatch("test", &loggedAction{"1", &calls, false}, &loggedAction{"2", &calls, false})
+	b.Do()
+	b.Undo()
+	checkResult(calls, []string{"Do 1", "Do 2", "Undo 2", "Undo 1"}, t)
+}
+
+func TestBatchRevertsOnFailure(t *testing.T) {
+	var calls []string
+	b := NewBatch("test", &loggedAction{"1", &calls, false}, &loggedAction{"2", &calls, true})
+	err := b.Do()
+	if err == nil {
+		t.Fatal("expected the batch to fail")
+	}
+	if e, ok := err.(*BatchError); !ok || e.Index != 1 || e.Partial {
+		t.Errorf("unexpected error %v", err)
+	}
+	checkResult(calls, []string{"Do 1", "Undo 1"}, t)
+}
+
+func TestActorBatch(t *testing.T) {
+	a := NewActor()
+	d1 := &dummyAction{}
+	d2 := &dummyAction{}
+	a.StartBatch("", "session")
+	a.AddAction("", d1)
+	a.AddAction("", d2)
+	if b := a.EndBatch(""); b == nil || b.Len() != 2 {
+		t.Fatal("batch was not ended with both actions")
+	}
+	if Describe(a.NextUndo("")) != "session" {
+		t.Errorf("next undo is %q", Describe(a.NextUndo("")))
+	}
+	a.Undo("")
+	checkResult(d1.Calls, []string{"Do", "Undo"}, t)
+	checkResult(d2.Calls, []string{"Do", "Undo"}, t)
+	if acted, _ := a.Undo(""); acted {
+		t.Error("undo acted past the start of the batch")
+	}
+}
+
+func TestUndoEndsBatch(t *testing.T) {
+	a := NewActor()
+	d := &dummyAction{}
+	a.StartBatch("", "session")
+	a.AddAction("", d)
+	a.Undo("")
+	checkResult(d.Calls, []string{"Do", "Undo"}, t)
+	if a.EndBatch("") != nil {
+		t.Error("batch is still open after undo")
+	}
+}
+
+type loggedAction struct {
+	name  string
+	calls *[]string
+	fail  bool
+}
+
+func (a *loggedAction) Do() error {
+	if a.fail {
+		return errors.New("failed")
+	}
+	*a.calls = append(*a.calls, "Do "+a.name)
+	return nil
+}
+
+func (a *loggedAction) Undo() error {
+	*a.calls = append(*a.calls, "Undo "+a.name)
+	return nil
+}
+
+func TestHistory(t *testing.T) {
+	a := NewActor()
+	a.AddAction("1", WithLabel("first", &dummyAction{}))
+	a.AddAction("1", WithLabel("second", &dummyAction{}))
+	a.Undo("1")
+
+	history := a.History("1")
+	if len(history) != 2 {
+		t.Fatalf("wanted 2 history entries got %d", len(history))
+	}
+	if history[0].Label != "first" || history[0].Undone || history[0].Time.IsZero() {
+		t.Errorf("unexpected first entry %+v", history[0])
+	}
+	if history[1].Label != "second" || !history[1].Undone {
+		t.Errorf("unexpected second entry %+v", history[1])
+	}
+	if Describe(a.NextUndo("1")) != "first" || Describe(a.NextRedo("1")) != "second" {
+		t.Errorf("next undo is %q and next redo is %q", Describe(a.NextUndo("1")), Describe(a.NextRedo("1")))
+	}
+	if a.History("2") != nil || len(a.IDs()) != 1 {
+		t.Error("inspecting history created an undo list")
+	}
+}
+
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.t
+}
+
+func newLimitedActor(l Limits) (Actor, *fakeClock) {
+	a := NewActor()
+	clock := &fakeClock{time.Date(2020, 1, 1, 18, 0, 0, 0, time.UTC)}
+	a.now = clock.now
+	a.SetLimits(l)
+	return a, clock
+}
+
+func TestUndoDepth(t *testing.T) {
+	a, _ := newLimitedActor(Limits{Depth: 2})
+	d1 := &dummyAction{}
+	d2 := &dummyAction{}
+	d3 := &dummyAction{}
+	a.AddAction("", d1)
+	a.AddAction("", d2)
+	a.AddAction("", d3)
+	a.Undo("")
+	a.Undo("")
+	if acted, _ := a.Undo(""); acted {
+		t.Error("undo acted beyond the depth limit")
+	}
+	checkResult(d1.Calls, []string{"Do"}, t)
+	checkResult(d2.Calls, []string{"Do", "Undo"}, t)
+	checkResult(d3.Calls, []string{"Do", "Undo"}, t)
+	if len(a.History("")) != 2 {
+		t.Errorf("wanted 2 history entries got %d", len(a.History("")))
+	}
+}
+
+func TestUndoMaxAge(t *testing.T) {
+	a, clock := newLimitedActor(Limits{MaxAge: 12 * time.Hour})
+	old := &dummyAction{}
+	recent := &dummyAction{}
+	a.AddAction("", old)
+	clock.t = clock.t.Add(10 * time.Hour)
+	a.AddAction("", recent)
+	clock.t = clock.t.Add(4 * time.Hour)
+
+	a.Undo("")
+	if acted, _ := a.Undo(""); acted {
+		t.Error("undo acted on an expired action")
+	}
+	checkResult(old.Calls, []string{"Do"}, t)
+	checkResult(recent.Calls, []string{"Do", "Undo"}, t)
+
+	if acted, _ := a.Redo(""); !acted {
+		t.Error("expiring actions discarded an action which can be redone")
+	}
+}
+
+func TestIdleListsAreCollected(t *testing.T) {
+	a, clock := newLimitedActor(Limits{IdleTimeout: time.Hour})
+	a.AddAction("x", &dummyAction{})
+	a.AddAction("1", &dummyAction{})
+	clock.t = clock.t.Add(45 * time.Minute)
+	a.AddAction("1", &dummyAction{})
+	clock.t = clock.t.Add(30 * time.Minute)
+
+	checkResult(a.IDs(), []string{"1"}, t)
+	if acted, _ := a.Undo("x"); acted {
+		t.Error("undo acted on a collected list")
+	}
+	if len(a.lists) != 1 {
+		t.Error("undo created a list for an unknown id")
+	}
+}
+
+func TestUndoOnlyListsAreNotCreated(t *testing.T) {
+	a := NewActor()
+	a.Undo("typo")
+	a.Redo("typo")
+	if len(a.lists) != 0 {
+		t.Errorf("wanted no lists got %d", len(a.lists))
+	}
+}
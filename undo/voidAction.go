@@ -0,0 +1,60 @@
+package undo
+
+// VoidAction takes back an earlier action, such as a drink served by
+// mistake, by undoing it. Undoing the VoidAction does the action again. Use
+// Actor.Find to get an action which can still be voided.
+type VoidAction struct {
+	action ReversibleAction
+}
+
+// NewVoidAction returns a VoidAction which takes back the given action
+func NewVoidAction(a ReversibleAction) *VoidAction {
+	return &VoidAction{a}
+}
+
+// Do implements the ReversibleAction interface
+func (a *VoidAction) Do() error {
+	return a.action.Undo()
+}
+
+// Undo implements the ReversibleAction interface
+func (a *VoidAction) Undo() error {
+	return a.action.Do()
+}
+
+// DoWith implements the TxAction interface
+func (a *VoidAction) DoWith(r Repository) error {
+	return undoActionWith(r)(a.action)
+}
+
+// UndoWith implements the TxAction interface
+func (a *VoidAction) UndoWith(r Repository) error {
+	return doActionWith(r)(a.action)
+}
+
+// find returns the newest action inside a for which match returns true,
+// looking inside batches and labels. Actions taken back by a VoidAction are
+// added to voided and skipped, which works because voids are always newer
+// than the actions they take back.
+func find(a ReversibleAction, match func(ReversibleAction) bool, voided map[ReversibleAction]bool) ReversibleAction {
+	switch a := a.(type) {
+	case *txAction:
+		return find(a.action, match, voided)
+	case *labeledAction:
+		return find(a.action, match, voided)
+	case *Batch:
+		for i := len(a.actions) - 1; i >= 0; i-- {
+			if found := find(a.actions[i], match, voided); found != nil {
+				return found
+			}
+		}
+		return nil
+	case *VoidAction:
+		voided[a.action] = true
+		return nil
+	}
+	if !voided[a] && match(a) {
+		return a
+	}
+	return nil
+}
@@ -4,12 +4,21 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/bhutch29/abv/model"
 	"github.com/jroimartin/gocui"
 	aur "github.com/logrusorgru/aurora"
 )
 
 var popupDisplayed = false
 
+// pendingID and pendingBarcode identify the unrecognized barcode which the
+// popup is adding a new drink for. Scans which arrive while the popup is
+// open do not affect them.
+var pendingID, pendingBarcode string
+
+// searchResults holds the drinks listed in the popup by the latest search.
+var searchResults []model.Drink
+
 // togglePopup toggles the popup window's visibility by bringing it to
 // either the very front or very back.
 func togglePopup() {
@@ -33,10 +42,10 @@ func togglePopup() {
 }
 
 // updatePromptSymbol highlights the selected mode.
-func updatePromptSymbol() {
+func updatePromptSymbol(mode Mode) {
 	v, _ := g.View(promptSymbol)
 	v.Clear()
-	switch mode := c.GetMode(); mode {
+	switch mode {
 	case stocking:
 		fmt.Fprintf(v, "%s >>", aur.BgBrown("Stocking"))
 	case serving:
@@ -45,6 +54,47 @@ func updatePromptSymbol() {
 
 }
 
+// updateUndoPanel shows what the next undo and redo would do for each input
+// device with any undo history.
+func updateUndoPanel(status Status) {
+	v, err := g.View(undoView)
+	if err != nil {
+		return
+	}
+	v.Clear()
+	if len(status.Undo) == 0 {
+		fmt.Fprint(v, "Nothing to undo")
+		return
+	}
+
+	names := make(map[string]string)
+	for _, s := range status.Scanners {
+		names[s.ID] = s.String()
+	}
+	for _, u := range status.Undo {
+		if len(status.Undo) > 1 || u.ID != "" {
+			name := names[u.ID]
+			if name == "" {
+				name = u.ID
+			}
+			if name == "" {
+				name = "keyboard"
+			}
+			fmt.Fprintf(v, "%s: ", aur.Bold(name))
+		}
+		undo, redo := u.Undo, u.Redo
+		if undo == "" {
+			undo = "nothing"
+		} else if !u.UndoTime.IsZero() {
+			undo += u.UndoTime.Format(" (15:04)")
+		}
+		if redo == "" {
+			redo = "nothing"
+		}
+		fmt.Fprintf(v, "undo %s, redo %s\n", undo, redo)
+	}
+}
+
 // clearView clears a given gocui view and hides the cursor.
 func clearView(view string) {
 	g.Update(func(g *gocui.Gui) error {
@@ -17,6 +17,7 @@ const (
 	search        = "Search"
 	searchSymbol  = "SearchSymbol"
 	searchOutline = "SearchOutline"
+	undoView      = "Undo"
 )
 
 const stockDivisor = 2
@@ -24,6 +25,7 @@ const stockDivisor = 2
 const (
 	inputHeight    = 4
 	inputCursorPos = 12
+	undoHeight     = 4
 
 	searchEntryHeight = 3
 	searchCursorPos   = 4
@@ -51,6 +53,10 @@ func (vd *viewDrawer) layout(g *gocui.Gui) (err error) {
 		logFile.Fatal(err)
 		return
 	}
+	if err = vd.makeUndoPanel(); err != nil {
+		logFile.Fatal(err)
+		return
+	}
 	if err = vd.makeSelectOptionsPopup(); err != nil {
 		logFile.Fatal(err)
 		return
@@ -136,7 +142,7 @@ func (vd *viewDrawer) makeSelectOptionsPopup() error {
 
 // makeInfoPanel creates the info view.
 func (vd *viewDrawer) makeInfoPanel() error {
-	viewHeight := vd.maxY - inputHeight
+	viewHeight := vd.maxY - inputHeight - undoHeight
 	infoStart := float64(vd.maxX) - float64(vd.maxX)/stockDivisor
 
 	if v, err := g.SetView(info, int(infoStart), 0, vd.maxX-2, viewHeight); err != nil {
@@ -151,19 +157,40 @@ func (vd *viewDrawer) makeInfoPanel() error {
 	return nil
 }
 
+// makeUndoPanel creates the undo view below the info view, which shows what
+// the next undo and redo would do.
+func (vd *viewDrawer) makeUndoPanel() error {
+	viewHeight := vd.maxY - inputHeight
+	undoStart := float64(vd.maxX) - float64(vd.maxX)/stockDivisor
+
+	if v, err := g.SetView(undoView, int(undoStart), viewHeight-undoHeight+1, vd.maxX-2, viewHeight); err != nil {
+		if err != gocui.ErrUnknownView {
+			return err
+		}
+		v.Title = "Undo"
+		v.Wrap = false
+		updateUndoPanel(c.Status())
+	}
+
+	return nil
+}
+
 // makePromptPanels creates the main prompt view, which contains the input
 // line and the keybinding hints.
+//
+// The controller status is only fetched when the views are created, since
+// fetching it may be a request to a remote station. After that, showResult
+// keeps the views up to date.
 func (vd *viewDrawer) makePromptPanels() error {
 	promptStartHeight := vd.maxY - inputHeight
 	promptDividerHeight := vd.maxY - (inputHeight / 2)
-	promptString := generateKeybindString(quantity)
 
 	if v, err := g.SetView(prompt, 0, promptStartHeight, vd.maxX, promptDividerHeight); err != nil {
 		if err != gocui.ErrUnknownView {
 			return err
 		}
 		v.Frame = false
-		fmt.Fprintf(v, promptString)
+		fmt.Fprint(v, generateKeybindString(c.Status().Quantity))
 	}
 
 	if v, err := g.SetView(input, inputCursorPos, promptDividerHeight, vd.maxX, vd.maxY); err != nil {
@@ -184,7 +211,7 @@ func (vd *viewDrawer) makePromptPanels() error {
 			return err
 		}
 		v.Frame = false
-		updatePromptSymbol()
+		updatePromptSymbol(c.Status().Mode)
 	}
 
 	return nil